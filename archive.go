@@ -0,0 +1,63 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"io"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// tarBlockSize is the size of a tar header block, which
+// contains the "ustar" magic bytes at offset 257.
+const tarBlockSize = 512
+
+// untar returns a reader for the first .txt file in a tar archive,
+// or the original content if the input is not a tar archive.
+func untar(r io.Reader) (io.Reader, error) {
+
+	// peek at the header to check if this is a tar archive
+	br := bufio.NewReaderSize(r, tarBlockSize)
+	b, _ := br.Peek(tarBlockSize)
+	if len(b) < tarBlockSize || string(b[257:262]) != "ustar" {
+		return br, nil
+	}
+
+	// find the first .txt file in the archive
+	tr := tar.NewReader(br)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("tar: expected .txt file in archive")
+		} else if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if hdr.Typeflag == tar.TypeReg && filepath.Ext(hdr.Name) == ".txt" {
+			return tr, nil
+		}
+	}
+}
+
+// unzip returns a reader for the first .txt file in a zip archive.
+func unzip(r io.ReaderAt, size int64) (io.ReadCloser, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() && filepath.Ext(f.Name) == ".txt" {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return rc, nil
+		}
+	}
+	return nil, errors.New("zip: expected .txt file in archive")
+}
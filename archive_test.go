@@ -0,0 +1,189 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testArchiveDict = "#! entries=2\n" +
+	"中 中 [Zhong1] /China/Chinese/surname Zhong/\n" +
+	"人 人 [ren2] /person/people/\n"
+
+// writeTestFile writes content to a file in a temporary directory.
+func writeTestFile(t *testing.T, name string, b []byte) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(filename, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// tarFiles returns a tar archive containing the named files.
+func tarFiles(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f[0], Mode: 0644, Size: int64(len(f[1]))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// zipFiles returns a zip archive containing the named files.
+func zipFiles(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// gzipBytes returns the input compressed in gzip format.
+func gzipBytes(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadTarGz(t *testing.T) {
+	b := tarFiles(t,
+		[2]string{"cedict/README", "not a dictionary"},
+		[2]string{"cedict/cedict_ts.txt", testArchiveDict},
+	)
+
+	for _, name := range []string{"cedict.tar", "cedict.tar.gz"} {
+		data := b
+		if filepath.Ext(name) == ".gz" {
+			data = gzipBytes(t, b)
+		}
+		d, err := Load(writeTestFile(t, name, data))
+		if err != nil {
+			t.Fatalf("%s: %+v", name, err)
+		}
+		if d.Metadata().Entries != 2 || d.GetByHanzi("人") == nil {
+			t.Errorf("%s: expected entries from archive", name)
+		}
+	}
+
+	// archive without a .txt file
+	b = gzipBytes(t, tarFiles(t, [2]string{"README", "not a dictionary"}))
+	_, err := Load(writeTestFile(t, "empty.tar.gz", b))
+	if err == nil || !strings.Contains(err.Error(), "expected .txt file") {
+		t.Errorf("got '%v', want 'expected .txt file'", err)
+	}
+}
+
+func TestLoadMisnamed(t *testing.T) {
+	files := map[string][]byte{
+		"gzipped.txt":  gzipBytes(t, []byte(testArchiveDict)),
+		"plain.txt.gz": []byte(testArchiveDict),
+		"plain.gz":     []byte(testArchiveDict),
+		"tar.txt":      gzipBytes(t, tarFiles(t, [2]string{"cedict_ts.txt", testArchiveDict})),
+	}
+	for name, b := range files {
+		d, err := Load(writeTestFile(t, name, b))
+		if err != nil {
+			t.Fatalf("%s: %+v", name, err)
+		}
+		if d.Metadata().Entries != 2 || d.GetByHanzi("人") == nil {
+			t.Errorf("%s: expected entries", name)
+		}
+	}
+}
+
+func TestLoadZip(t *testing.T) {
+	b := zipFiles(t,
+		[2]string{"README", "not a dictionary"},
+		[2]string{"cedict_ts.txt", testArchiveDict},
+	)
+
+	// read directly from memory
+	rc, err := unzip(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Parse(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.GetByHanzi("人") == nil {
+		t.Errorf("expected entries from zip archive")
+	}
+
+	// load from file
+	d, err = Load(writeTestFile(t, "cedict.zip", b))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if d.Metadata().Entries != 2 {
+		t.Errorf("got %d entries (want 2)", d.Metadata().Entries)
+	}
+
+	// archive without a .txt file
+	b = zipFiles(t, [2]string{"README", "not a dictionary"})
+	_, err = Load(writeTestFile(t, "empty.zip", b))
+	if err == nil || !strings.Contains(err.Error(), "expected .txt file") {
+		t.Errorf("got '%v', want 'expected .txt file'", err)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cedict.txt":         {Data: []byte(testArchiveDict)},
+		"dict/cedict.txt.gz": {Data: gzipBytes(t, []byte(testArchiveDict))},
+		"cedict.zip":         {Data: zipFiles(t, [2]string{"cedict_ts.txt", testArchiveDict})},
+	}
+	for name := range fsys {
+		d, err := LoadFS(fsys, name)
+		if err != nil {
+			t.Fatalf("%s: %+v", name, err)
+		}
+		if d.Metadata().Entries != 2 || d.GetByHanzi("人") == nil {
+			t.Errorf("%s: expected entries", name)
+		}
+	}
+
+	if _, err := LoadFS(fsys, "missing.txt"); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
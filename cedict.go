@@ -6,18 +6,27 @@ package cedict
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 	"golang.org/x/text/runes"
@@ -38,6 +47,22 @@ const (
 
 	// MaxLD controls the max levenshtein distance allowed for matches.
 	MaxLD = 10
+
+	// DefaultMinQueryLength is the shortest GetByMeaning query, in
+	// characters, before SetMinQueryLength is called.
+	DefaultMinQueryLength = 2
+
+	// ToneWildcard can replace a tone number in GetByPinyin
+	// input to match any tone, i.e. "zhong1 wen?".
+	ToneWildcard = '?'
+
+	// parallelParseMin is the number of entries before Parse uses
+	// multiple workers to unmarshal entries.
+	parallelParseMin = 10000
+
+	// parallelSearchMin is the number of entries before GetByMeaning
+	// uses multiple workers to scan meanings.
+	parallelSearchMin = 10000
 )
 
 var (
@@ -45,19 +70,73 @@ var (
 	loadOnce sync.Once
 )
 
+// DoubleUFallback enables "uu" as an ASCII fallback for ü in pinyin, i.e.
+// "luu4" for lü4, in addition to "u:" and "v" which are always supported.
+// It is disabled by default as it is less standard. It should be set before
+// converting pinyin, as changing it isn't safe for concurrent use.
+var DoubleUFallback = false
+
+var (
+	// ErrDownload is matched by errors returned when downloading
+	// the CC-CEDICT fails, i.e. errors.Is(err, ErrDownload).
+	ErrDownload = errors.New("download failed")
+
+	// ErrParse is matched by errors returned when parsing
+	// CC-CEDICT content fails, i.e. errors.Is(err, ErrParse).
+	ErrParse = errors.New("parse failed")
+)
+
+// loadError wraps an error with the kind of failure, so it can
+// be matched by errors.Is as either the kind or underlying error.
+type loadError struct {
+	kind error
+	err  error
+}
+
+func (e *loadError) Error() string {
+	return fmt.Sprintf("%v: %v", e.kind, e.err)
+}
+
+func (e *loadError) Unwrap() error {
+	return e.err
+}
+
+func (e *loadError) Is(target error) bool {
+	return target == e.kind
+}
+
 /*
 	todo: look into "github.com/yanyiwu/gojieba"
 */
 
 // Dict represents an instance of the CC-CEDICT entries.
 // By default, the latest version will be downloaded on creation.
+// Entries returned by lookup methods are shared with the Dict, so
+// they must not be modified, use Entry.Clone to get a safe copy.
 type Dict struct {
 	e      []*Entry
 	md     Metadata
 	ready  chan bool
+	done   chan struct{}
 	header []string
+	notes  map[int][]string
 	mutex  sync.Mutex
 	err    error
+	words  map[string][]int
+	stems  map[string][]int
+	hanzi  map[string][]int
+	folded map[string][]int
+	trie   *trie
+	toSimp map[rune]rune
+	toTrad map[rune]rune
+
+	collation *collation
+	readings  *readings
+	minQuery  int
+	wordLen   int
+
+	// open returns the CC-CEDICT loaded by lazyLoad, or Download if nil
+	open func() (io.ReadCloser, error)
 }
 
 // Entry represents a single entry in the CC-CEDICT dictionary.
@@ -68,6 +147,19 @@ type Entry struct {
 	Meanings    []string
 }
 
+// GroupedEntry represents all entries for the same hanzi,
+// combined into a single record with multiple readings.
+type GroupedEntry struct {
+	Hanzi    string
+	Readings []Reading
+}
+
+// Reading represents the pinyin and meanings of a GroupedEntry.
+type Reading struct {
+	Pinyin   string
+	Meanings []string
+}
+
 // Metadata represents information embedded in the CC-CEDICT header.
 type Metadata struct {
 	Version    int
@@ -78,82 +170,144 @@ type Metadata struct {
 	Publisher  string
 	License    string
 	Timestamp  time.Time
+	Time       int64
+}
+
+// MeaningMatch is an entry returned by GetByMeaningMatches,
+// along with the meaning which matched the query.
+type MeaningMatch struct {
+	Entry   *Entry
+	Meaning string
+}
+
+// ScoredEntry is an entry returned by SearchPinyinFuzzy, along with
+// a score between 0 and 1 of how closely it matched, 1 being exact.
+type ScoredEntry struct {
+	Entry *Entry
+	Score float64
+}
+
+// CharSyllable is a character of an entry's hanzi, along with the pinyin
+// syllable aligned to it, as returned by CharPinyin.
+type CharSyllable struct {
+	Char   string
+	Pinyin string
+}
+
+// Stats represents summary counts of the entries in a Dict.
+type Stats struct {
+	Entries     int
+	SingleChar  int
+	MultiChar   int
+	Classifiers int
+	Syllables   int
+	AvgMeanings float64
 }
 
 // Parse creates a Dict instance from an io.Reader
 // It expects text input in the format, https://cc-cedict.org/wiki/format:syntax
+// Large inputs are unmarshalled in parallel, preserving entry order.
+// Errors returned match ErrParse.
 func Parse(r io.Reader) (*Dict, error) {
+	d, err := parse(r, 0)
+	if err != nil {
+		return nil, &loadError{ErrParse, err}
+	}
+	return d, nil
+}
+
+// ParseString creates a Dict instance from a string, as per Parse.
+func ParseString(s string) (*Dict, error) {
+	return Parse(strings.NewReader(s))
+}
+
+// NewFromEntries creates a ready Dict from the entries, without any
+// download or parsing. The metadata entry count is set to the number
+// of entries, and a header is generated from the metadata for Save.
+// Nil entries are skipped, and the entries themselves are not copied.
+func NewFromEntries(entries []*Entry, md Metadata) *Dict {
+	d := newDict()
+	for _, e := range entries {
+		if e != nil {
+			d.e = append(d.e, e)
+		}
+	}
+	d.md = md
+	d.md.Entries = len(d.e)
+	d.header = metadataHeader(d.md)
+	d.buildIndex()
+
+	// unblock dict methods
+	d.setReady()
+
+	return d
+}
+
+// parse creates a Dict instance from an io.Reader, unmarshalling
+// entries across the number of workers, or GOMAXPROCS workers for
+// large inputs if workers is zero.
+func parse(r io.Reader, workers int) (*Dict, error) {
 	d := newDict()
 	scanner := bufio.NewScanner(r)
 
+	var lines []string
+	var lineNums []int
+
 	// scan lines from text input
-	for scanner.Scan() {
+	for n := 1; scanner.Scan(); n++ {
 		line := scanner.Text()
 
+		// skip blank lines, which aren't counted as entries
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
 		// is this a comment line?
 		if strings.HasPrefix(line, "#") {
-			d.header = append(d.header, line)
 
-			// does the line include metadata?
-			if strings.HasPrefix(line, "#!") {
-				i := strings.Index(line, "=")
-				v := line[i+1:]
-				k := line[3:i]
-
-				// parse metadata value
-				switch k {
-				case "version":
-					n, err := strconv.Atoi(v)
-					if err != nil {
-						return nil, errors.Wrap(err, "version: expected number")
-					}
-					d.md.Version = n
-
-				case "subversion":
-					n, err := strconv.Atoi(v)
-					if err != nil {
-						return nil, errors.Wrap(err, "subversion: expected number")
-					}
-					d.md.Subversion = n
-
-				case "format":
-					d.md.Format = v
-
-				case "charset":
-					d.md.Charset = v
-
-				case "entries":
-					n, err := strconv.Atoi(v)
-					if err != nil {
-						return nil, errors.Wrap(err, "entries: expected number")
-					}
-					d.md.Entries = n
-
-				case "publisher":
-					d.md.Publisher = v
-
-				case "license":
-					d.md.License = v
-
-				case "date":
-					t, err := time.Parse(time.RFC3339, v)
-					if err != nil {
-						return nil, errors.Wrap(err, "date: expected RFC3339 format")
-					}
-					d.md.Timestamp = t
+			// comments after the first entry are kept in position,
+			// attached to the entry that follows them
+			if len(lines) == 0 {
+				d.header = append(d.header, line)
+			} else {
+				if d.notes == nil {
+					d.notes = make(map[int][]string)
 				}
+				d.notes[len(lines)] = append(d.notes[len(lines)], line)
+			}
+
+			// does the line include metadata?
+			if err := parseMetadata(&d.md, line); err != nil {
+				return nil, err
 			}
 
 			// skip commented lines
 			continue
 		}
 
-		// add entry to dict
-		e := &Entry{}
-		if err := e.Unmarshal(line); err != nil {
-			return nil, errors.Wrap(err, "unmarshal: "+line)
+		// queue entry for unmarshalling
+		lines = append(lines, line)
+		lineNums = append(lineNums, n)
+	}
+
+	// use parallel workers for large inputs
+	if workers <= 0 {
+		workers = 1
+		if len(lines) >= parallelParseMin {
+			workers = runtime.GOMAXPROCS(0)
 		}
-		d.e = append(d.e, e)
+	}
+
+	// add entries to dict
+	entries, err := unmarshalEntries(lines, lineNums, workers)
+	if err != nil {
+		return nil, err
+	}
+	d.e = entries
+
+	// validate header date and time agree
+	if err := validateMetadata(d.md); err != nil {
+		return nil, err
 	}
 
 	// validate header entry count
@@ -162,34 +316,376 @@ func Parse(r io.Reader) (*Dict, error) {
 			len(d.e), d.md.Entries)
 	}
 
+	// build lookup indexes
+	d.buildIndex()
+
 	// unblock dict methods
 	d.setReady()
 
 	return d, nil
 }
 
+// parseMetadata sets the metadata value from a header comment line,
+// i.e. "#! version=1". Other comment lines are ignored.
+func parseMetadata(md *Metadata, line string) error {
+	i := strings.Index(line, "=")
+	if !strings.HasPrefix(line, "#! ") || i < 0 {
+		return nil
+	}
+	v := line[i+1:]
+	k := line[3:i]
+
+	// parse metadata value
+	switch k {
+	case "version":
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.Wrap(err, "version: expected number")
+		}
+		md.Version = n
+
+	case "subversion":
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.Wrap(err, "subversion: expected number")
+		}
+		md.Subversion = n
+
+	case "format":
+		md.Format = v
+
+	case "charset":
+		md.Charset = v
+
+	case "entries":
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.Wrap(err, "entries: expected number")
+		}
+		md.Entries = n
+
+	case "publisher":
+		md.Publisher = v
+
+	case "license":
+		md.License = v
+
+	case "date":
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return errors.Wrap(err, "date: expected RFC3339 format")
+		}
+		md.Timestamp = t
+
+	case "time":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "time: expected unix time")
+		}
+		md.Time = n
+	}
+	return nil
+}
+
+// validateMetadata returns an error if the header "date" and
+// "time" values are both set, but are not the same time.
+func validateMetadata(md Metadata) error {
+	if md.Time != 0 && !md.Timestamp.IsZero() && md.Timestamp.Unix() != md.Time {
+		return fmt.Errorf("time (%d) != date (%s)",
+			md.Time, md.Timestamp.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// String returns a summary of the metadata for display, i.e.
+// "CC-CEDICT v1.0 (2020-02-14), 4 entries, MDBG, CC-BY-SA".
+// Values which aren't set are omitted.
+func (md Metadata) String() string {
+	s := "CC-CEDICT"
+	if md.Version != 0 || md.Subversion != 0 {
+		s += fmt.Sprintf(" v%d.%d", md.Version, md.Subversion)
+	}
+	if !md.Timestamp.IsZero() {
+		s += md.Timestamp.Format(" (2006-01-02)")
+	}
+	parts := []string{s, fmt.Sprintf("%d entries", md.Entries)}
+	if md.Publisher != "" {
+		parts = append(parts, md.Publisher)
+	}
+	if md.License != "" {
+		parts = append(parts, licenseName(md.License))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// licenseName returns the short name of creative commons licenses,
+// i.e. "https://creativecommons.org/licenses/by-sa/4.0/" -> "CC-BY-SA",
+// or the license unchanged.
+func licenseName(license string) string {
+	const prefix = "creativecommons.org/licenses/"
+	i := strings.Index(license, prefix)
+	if i < 0 {
+		return license
+	}
+	name := strings.SplitN(license[i+len(prefix):], "/", 2)[0]
+	if name == "" {
+		return license
+	}
+	return "CC-" + strings.ToUpper(name)
+}
+
+// metadataHeader returns the header comment lines for the metadata,
+// omitting any values which aren't set.
+func metadataHeader(md Metadata) []string {
+	var header []string
+	add := func(k, v string) {
+		header = append(header, "#! "+k+"="+v)
+	}
+	if md.Version != 0 {
+		add("version", strconv.Itoa(md.Version))
+	}
+	if md.Subversion != 0 {
+		add("subversion", strconv.Itoa(md.Subversion))
+	}
+	if md.Format != "" {
+		add("format", md.Format)
+	}
+	if md.Charset != "" {
+		add("charset", md.Charset)
+	}
+	add("entries", strconv.Itoa(md.Entries))
+	if md.Publisher != "" {
+		add("publisher", md.Publisher)
+	}
+	if md.License != "" {
+		add("license", md.License)
+	}
+	if !md.Timestamp.IsZero() {
+		add("date", md.Timestamp.Format(time.RFC3339))
+	}
+	if md.Time != 0 {
+		add("time", strconv.FormatInt(md.Time, 10))
+	}
+	return header
+}
+
+// ParseHeader reads only the header comment lines from an io.Reader,
+// stopping at the first entry, and returns the metadata along with the
+// header lines. This is faster than Parse when only metadata is needed.
+// Errors returned match ErrParse.
+func ParseHeader(r io.Reader) (Metadata, []string, error) {
+	var md Metadata
+	var header []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		header = append(header, line)
+		if err := parseMetadata(&md, line); err != nil {
+			return Metadata{}, nil, &loadError{ErrParse, err}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Metadata{}, nil, &loadError{ErrParse, errors.WithStack(err)}
+	}
+	if err := validateMetadata(md); err != nil {
+		return Metadata{}, nil, &loadError{ErrParse, err}
+	}
+	return md, header, nil
+}
+
+// unmarshalEntries unmarshals entry lines, split into a chunk for each
+// worker. Entries are returned in order, along with the first error.
+func unmarshalEntries(lines []string, lineNums []int, workers int) ([]*Entry, error) {
+	entries := make([]*Entry, len(lines))
+	errs := make([]error, workers)
+	chunk := (len(lines) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo := w * chunk
+		hi := lo + chunk
+		if hi > len(lines) {
+			hi = len(lines)
+		}
+		if lo >= hi {
+			break
+		}
+
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				e := &Entry{}
+				if err := e.Unmarshal(lines[i]); err != nil {
+					errs[w] = errors.Wrapf(err, "line %d: unmarshal: %s", lineNums[i], lines[i])
+					return
+				}
+				entries[i] = e
+			}
+		}(w, lo, hi)
+	}
+	wg.Wait()
+
+	// chunks are ordered, so the first error is the earliest line
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
 // Download returns a Dict using the latest CC-CEDICT archive from MDBG.
 // This file is regularly updated but relatively small at approx 4MB.
-func Download() (io.ReadCloser, error) {
+// The content is decompressed if gzip, see WithURL for other sources.
+// Errors returned match ErrDownload.
+func Download(opts ...DownloadOption) (io.ReadCloser, error) {
+	return download(context.Background(), URL, opts...)
+}
 
-	resp, err := http.Get(URL)
+// LatestVersion returns the version and date of the latest CC-CEDICT,
+// downloading only the header, so it can be compared to the Metadata
+// of a loaded Dict to check for updates. Errors returned match
+// ErrDownload or ErrParse.
+func LatestVersion(ctx context.Context) (version, subversion int, date time.Time, err error) {
+	md, err := latestVersion(ctx, URL)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return 0, 0, time.Time{}, err
+	}
+	return md.Version, md.Subversion, md.Timestamp, nil
+}
+
+// latestVersion returns the metadata from the header of the gzip file
+// at the url, closing the connection once the header is read.
+func latestVersion(ctx context.Context, url string) (Metadata, error) {
+	r, err := download(ctx, url)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer r.Close()
+
+	md, _, err := ParseHeader(r)
+	return md, err
+}
+
+// DownloadOption configures the behaviour of Download.
+type DownloadOption func(*downloadOptions)
+
+// downloadOptions holds the settings applied by DownloadOption.
+type downloadOptions struct {
+	sha256 string
+	url    string
+}
+
+// WithURL downloads from the url instead of the MDBG export URL.
+// The file may be gzip compressed or plain text, which is detected
+// from the content rather than the file extension.
+func WithURL(url string) DownloadOption {
+	return func(o *downloadOptions) {
+		o.url = url
+	}
+}
+
+// WithSHA256 verifies the downloaded gzip file against the expected
+// hex encoded SHA-256 checksum, returning an error on mismatch.
+// This can be used to pin a known version of the CC-CEDICT.
+func WithSHA256(sum string) DownloadOption {
+	return func(o *downloadOptions) {
+		o.sha256 = strings.ToLower(sum)
+	}
+}
+
+// download returns the decompressed body of the gzip file at the url,
+// or the body as-is if it isn't gzip compressed.
+func download(ctx context.Context, url string, opts ...DownloadOption) (io.ReadCloser, error) {
+	o := &downloadOptions{url: url}
+	for _, opt := range opts {
+		opt(o)
+	}
+	url = o.url
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &loadError{ErrDownload, errors.WithStack(err)}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, &loadError{ErrDownload, errors.WithStack(err)}
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %s", resp.Status)
+		resp.Body.Close()
+		return nil, &loadError{ErrDownload, fmt.Errorf("bad status: %s", resp.Status)}
+	}
+
+	// verify checksum before decompressing
+	var body io.ReadCloser = resp.Body
+	if o.sha256 != "" {
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, &loadError{ErrDownload, errors.WithStack(err)}
+		}
+		sum := sha256.Sum256(b)
+		if got := hex.EncodeToString(sum[:]); got != o.sha256 {
+			return nil, &loadError{ErrDownload,
+				fmt.Errorf("checksum mismatch: got %s, want %s", got, o.sha256)}
+		}
+		body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	// plain text is returned without decompressing
+	br := bufio.NewReader(body)
+	if !isGzip(br) {
+		return &readCloser{Reader: br, Closer: body}, nil
 	}
 
-	gz, err := gzip.NewReader(resp.Body)
+	gz, err := gzip.NewReader(br)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		body.Close()
+		return nil, &loadError{ErrDownload, errors.WithStack(err)}
 	}
 
-	return gz, nil
+	return &gzipBody{Reader: gz, body: body}, nil
+}
+
+// isGzip returns true if the buffered content starts with the gzip
+// magic bytes, without consuming them.
+func isGzip(br *bufio.Reader) bool {
+	b, _ := br.Peek(2)
+	return len(b) == 2 && b[0] == 0x1f && b[1] == 0x8b
+}
+
+// readCloser is a reader which closes the underlying body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// gzipBody is a decompressed response body, which closes
+// both the gzip reader and the underlying body.
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (g *gzipBody) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
 }
 
 // Load returns a Dict loaded from a CC-CEDICT formatted file.
+// Gzip files are decompressed, detected from the content rather than
+// the extension, and if the content is a tar archive, the first '.txt'
+// file inside it is loaded. Files ending in '.zip' load the first '.txt'
+// file in the zip archive.
 // This is provided for completeness, but I encourage you to
 // use default behaviour of downloading the latest dict each time.
 func Load(filename string) (*Dict, error) {
@@ -200,14 +696,57 @@ func Load(filename string) (*Dict, error) {
 	}
 	defer f.Close()
 
+	return load(f, filename)
+}
+
+// LoadFS returns a Dict loaded from a CC-CEDICT formatted file in the
+// filesystem, such as an embed.FS, handling archives the same as Load.
+func LoadFS(fsys fs.FS, name string) (*Dict, error) {
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	return load(f, name)
+}
+
+// load returns a Dict parsed from the opened file, decompressing gzip
+// content and extracting zip archives based on the file name extension.
+func load(f fs.File, name string) (*Dict, error) {
+
 	var r io.Reader = f
-	if filepath.Ext(filename) == ".gz" {
-		gz, err := gzip.NewReader(f)
+	if path.Ext(name) == ".zip" {
+		ra, size, err := readerAt(f)
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return nil, err
 		}
-		defer gz.Close()
-		r = gz
+		zr, err := unzip(ra, size)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+
+		// decompress gzip content, regardless of the extension
+		br := bufio.NewReader(f)
+		r = br
+		if isGzip(br) {
+			gz, err := gzip.NewReader(br)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+	}
+
+	// extract from tar archive, if needed
+	r, err := untar(r)
+	if err != nil {
+		return nil, err
 	}
 
 	dict, err := Parse(r)
@@ -218,6 +757,23 @@ func Load(filename string) (*Dict, error) {
 	return dict, nil
 }
 
+// readerAt returns the file as an io.ReaderAt along with its size,
+// reading it into memory if it doesn't support random access.
+func readerAt(f fs.File) (io.ReaderAt, int64, error) {
+	if ra, ok := f.(io.ReaderAt); ok {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, 0, errors.WithStack(err)
+		}
+		return ra, fi.Size(), nil
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return bytes.NewReader(b), int64(len(b)), nil
+}
+
 // New returns a Dict immediately but downloads the latest
 // CC-CEDICT data in the background. Dict methods can be
 // safely called, but will block until parsing is complete.
@@ -232,21 +788,45 @@ func New() *Dict {
 // newDict creates a new Dict struct.
 func newDict() *Dict {
 	return &Dict{
-		ready: make(chan bool),
+		ready:    make(chan bool),
+		done:     make(chan struct{}),
+		minQuery: DefaultMinQueryLength,
 	}
 }
 
+// SetMinQueryLength sets the shortest query, in characters, searched by
+// GetByMeaning. Shorter queries return no entries, as they would match
+// much of the dictionary. Zero allows queries of any length.
+func (d *Dict) SetMinQueryLength(n int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.minQuery = n
+}
+
 // Err blocks until the Dict is finished parsing and then
 // returns any errors encountered during loading/download.
 func (d *Dict) Err() error {
-	d.lazyLoad()
+	d = d.snapshot()
 	return d.err
 }
 
+// Ready returns true if the Dict has finished loading, without blocking.
+// This can be used to poll the background load started by New.
+func (d *Dict) Ready() bool {
+	return d.isReady()
+}
+
+// Done returns a channel that is closed when the Dict has finished
+// loading, or failed to load, so it can be used in a select statement.
+// Err should be checked once the channel is closed.
+func (d *Dict) Done() <-chan struct{} {
+	return d.done
+}
+
 // DefaultFilename returns the CC-CEDICT filename format.
 // constructed using the Dict's parsed metadata.
 func (d *Dict) DefaultFilename() string {
-	d.lazyLoad()
+	d = d.snapshot()
 	return fmt.Sprintf("cedict_%d_%d_%s_%s_%s.txt.gz",
 		d.md.Version, d.md.Subversion, strings.ToLower(d.md.Format),
 		strings.ToLower(d.md.Charset), strings.ToLower(d.md.Publisher))
@@ -257,7 +837,20 @@ func (d *Dict) DefaultFilename() string {
 // be identical to the unpacked CC-CEDICT file download.
 // Saved as gzip archive if filename ends in '.gz'.
 func (d *Dict) Save(filename string) error {
-	d.lazyLoad()
+	return d.SaveWithLineEnding(filename, LineEnding)
+}
+
+// SaveWithLineEnding writes the Dict to a file as per Save, using the
+// line ending given instead of LineEnding, i.e. "\n" for unix tools.
+func (d *Dict) SaveWithLineEnding(filename, lineEnding string) error {
+	d = d.snapshot()
+
+	// meanings containing slashes would be split when loaded
+	for _, e := range d.e {
+		if _, err := JoinMeanings(e.Meanings); err != nil {
+			return errors.Wrapf(err, "entry '%s'", e.Traditional)
+		}
+	}
 
 	// create file, overwrite if needed
 	f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
@@ -277,16 +870,24 @@ func (d *Dict) Save(filename string) error {
 	// write commented lines
 	for i, line := range d.header {
 		if i != len(d.header)-1 {
-			line += LineEnding
+			line += lineEnding
 		}
 		if _, err := w.Write([]byte(line)); err != nil {
 			return errors.WithStack(err)
 		}
 	}
 
-	// write dict entries
-	for _, e := range d.e {
-		line := LineEnding + e.Marshal()
+	// write dict entries, preceded by their comments
+	for i := 0; i <= len(d.e); i++ {
+		for _, line := range d.notes[i] {
+			if _, err := w.Write([]byte(lineEnding + line)); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		if i == len(d.e) {
+			break
+		}
+		line := lineEnding + d.e[i].Marshal()
 		if _, err := w.Write([]byte(line)); err != nil {
 			return errors.WithStack(err)
 		}
@@ -295,45 +896,541 @@ func (d *Dict) Save(filename string) error {
 	return nil
 }
 
-// Metadata returns the Dict's metadata parsed from header comments.
-func (d *Dict) Metadata() Metadata {
-	d.lazyLoad()
-	return d.md
-}
-
-// GetByHanzi returns the Dict entry for the hanzi, if found.
-// Supports input using traditional or simplified characters.
-func (d *Dict) GetByHanzi(s string) *Entry {
-	d.lazyLoad()
-	s = strings.TrimSpace(s)
+// Filter returns a new Dict containing only entries matching the predicate.
+// Metadata and header comments are copied, with the entry count corrected.
+func (d *Dict) Filter(pred func(*Entry) bool) *Dict {
+	d = d.snapshot()
+	dict := newDict()
 	for _, e := range d.e {
-		if e.Traditional == s || e.Simplified == s {
-			return e
+		if pred(e) {
+			dict.e = append(dict.e, e)
 		}
 	}
-	return nil
-}
 
-// GetByPinyin returns hanzi matching the given pinyin string.
-// Supports pinyin in plaintext or with tones/tone numbers.
-// With plaintext, all tone variations are considered matching.
-func (d *Dict) GetByPinyin(s string) []*Entry {
-	d.lazyLoad()
+	// copy metadata + header, correcting entry count
+	dict.md = d.md
+	dict.md.Entries = len(dict.e)
+	dict.header = setHeaderValue(d.header, "entries", strconv.Itoa(len(dict.e)))
+	dict.buildIndex()
 
-	// convert tones to tone numbers
-	s = PinyinToneNums(s)
-	isPlaintext := strings.IndexAny(s, toneNums) < 0
+	// unblock dict methods
+	dict.setReady()
 
-	// normalise pinyin to lowercase, no spaces
-	s = strings.ToLower(s)
-	s = strings.ReplaceAll(s, " ", "")
+	return dict
+}
+
+// SaveSubset writes only the entries matching the predicate to a file,
+// in the same format as Save with the header entry count corrected.
+func (d *Dict) SaveSubset(filename string, pred func(*Entry) bool) error {
+	return d.Filter(pred).Save(filename)
+}
 
+// SingleCharEntries returns entries with a single simplified character.
+func (d *Dict) SingleCharEntries() []*Entry {
+	d = d.snapshot()
 	var results []*Entry
 	for _, e := range d.e {
+		if len([]rune(e.Simplified)) == 1 {
+			results = append(results, e)
+		}
+	}
+	return results
+}
 
-		// normalise entry pinyin to lowercase, no spaces
+// Idioms returns entries with a meaning marked "(idiom)", which are
+// typically four character chengyu, i.e. 画蛇添足.
+func (d *Dict) Idioms() []*Entry {
+	return d.idioms(0)
+}
+
+// FourCharIdioms returns entries marked as idioms, as per Idioms,
+// which have exactly four characters.
+func (d *Dict) FourCharIdioms() []*Entry {
+	return d.idioms(4)
+}
+
+// idioms returns entries marked as idioms with n characters, or any
+// number of characters if n is zero.
+func (d *Dict) idioms(n int) []*Entry {
+	d = d.snapshot()
+	var results []*Entry
+	for _, e := range d.e {
+		if n > 0 && len([]rune(e.Simplified)) != n {
+			continue
+		}
+		for _, m := range e.Meanings {
+			if strings.Contains(m, "(idiom)") {
+				results = append(results, e)
+				break
+			}
+		}
+	}
+	return results
+}
+
+// Stats returns summary counts of the Dict's entries. Classifiers counts
+// entries with a "CL:" meaning and Syllables counts distinct lowercase
+// pinyin syllables with tone numbers, i.e. "zhong1".
+func (d *Dict) Stats() Stats {
+	d = d.snapshot()
+	var st Stats
+	meanings := 0
+	syllables := make(map[string]bool)
+	for _, e := range d.e {
+		st.Entries++
+		if len([]rune(e.Simplified)) == 1 {
+			st.SingleChar++
+		} else {
+			st.MultiChar++
+		}
+		for _, m := range e.Meanings {
+			if strings.HasPrefix(m, "CL:") {
+				st.Classifiers++
+				break
+			}
+		}
+		for _, p := range pinyinSyllables(e.Pinyin) {
+			syllables[strings.ToLower(p)] = true
+		}
+		meanings += len(e.Meanings)
+	}
+	st.Syllables = len(syllables)
+	if st.Entries > 0 {
+		st.AvgMeanings = float64(meanings) / float64(st.Entries)
+	}
+	return st
+}
+
+// Len returns the number of entries in the Dict.
+func (d *Dict) Len() int {
+	d = d.snapshot()
+	return len(d.e)
+}
+
+// EntryAt returns the entry at index i, in the order parsed, and true.
+// If i is out of range, nil and false are returned. Used with Len, it
+// allows iterating over the Dict without access to the entries slice.
+func (d *Dict) EntryAt(i int) (*Entry, bool) {
+	d = d.snapshot()
+	if i < 0 || i >= len(d.e) {
+		return nil, false
+	}
+	return d.e[i], true
+}
+
+// Metadata returns the Dict's metadata parsed from header comments.
+func (d *Dict) Metadata() Metadata {
+	d = d.snapshot()
+	return d.md
+}
+
+// SetMetadata replaces the Dict's metadata, regenerating the "#!" header
+// lines written by Save, so derived dicts don't keep stale values. The
+// entry count is always set to the number of entries. Other header
+// comments are kept, and the metadata is replaced again by Reload.
+func (d *Dict) SetMetadata(md Metadata) {
+	d.lazyLoad()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	md.Entries = len(d.e)
+	d.md = md
+	d.header = replaceMetadataHeader(d.header, md)
+}
+
+// Validate returns errors for entries where the number of pinyin syllables
+// doesn't match the number of hanzi characters. Entries containing non-hanzi
+// characters (i.e. 3C) are skipped. At most MaxResults errors are returned.
+func (d *Dict) Validate() []error {
+	d = d.snapshot()
+	var errs []error
+	for i, e := range d.e {
+
+		// skip known exceptions, such as latin letters or digits
+		if !IsHanzi(e.Traditional) || !IsHanzi(e.Simplified) {
+			continue
+		}
+
+		if err := e.validateSyllables(); err != nil {
+			errs = append(errs, errors.Wrapf(err, "entry %d: %s", i+1, e.Marshal()))
+			if len(errs) >= MaxResults {
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// Search returns entries matching the query, detecting whether it is
+// hanzi (see GetAllByHanzi), pinyin (see GetByPinyin) or english (see
+// GetByMeaning). Headwords which aren't all hanzi, such as 卡拉OK, are
+// also matched. Queries made up of valid pinyin syllables are searched
+// by meaning if no pinyin entries match, as english words such as "he"
+// are also valid syllables.
+func (d *Dict) Search(query string) []*Entry {
+	query = strings.TrimSpace(query)
+	if IsHanzi(query) {
+		return d.GetAllByHanzi(query)
+	}
+
+	// headwords with latin letters, i.e. 卡拉OK
+	if results := d.GetAllByHanzi(query); len(results) > 0 {
+		return results
+	}
+	if IsPinyin(query) {
+		if results := d.GetByPinyin(query); len(results) > 0 {
+			return results
+		}
+	}
+	return d.GetByMeaning(query)
+}
+
+// GetByHanzi returns the Dict entry for the hanzi, if found.
+// Supports input using traditional or simplified characters.
+// Entries with non-hanzi headwords, such as "% % [pa1] /percent (Tw)/",
+// are also found i.e. GetByHanzi("%"), although IsHanzi rejects them
+// and HanziToPinyin leaves them unconverted. Latin letters in headwords,
+// such as 卡拉OK, match in any case if there is no exact match.
+func (d *Dict) GetByHanzi(s string) *Entry {
+	d = d.snapshot()
+	if ids := d.hanziIDs(s); len(ids) > 0 {
+		return d.e[ids[0]]
+	}
+	return nil
+}
+
+// hanziIDs returns the ids of entries for the hanzi, matching latin
+// letters in any case if there is no exact match.
+func (d *Dict) hanziIDs(s string) []int {
+	s = strings.TrimSpace(s)
+	if ids, ok := d.hanzi[s]; ok {
+		return ids
+	}
+	return d.folded[strings.ToLower(s)]
+}
+
+// FuzzyHanzi returns entries with traditional or simplified hanzi within
+// the levenshtein distance of the input, comparing characters. This helps
+// find words when a character is mistyped. Results are sorted by distance.
+func (d *Dict) FuzzyHanzi(s string, maxDist int) []*Entry {
+	d = d.snapshot()
+	s = strings.TrimSpace(s)
+	n := utf8.RuneCountInString(s)
+	if n == 0 {
+		return nil
+	}
+
+	var results []*Entry
+	dist := make(map[*Entry]int)
+	for _, e := range d.e {
+
+		// skip entries with too many extra or missing characters
+		if abs(utf8.RuneCountInString(e.Simplified)-n) > maxDist {
+			continue
+		}
+
+		ld := levenshtein(s, e.Simplified)
+		if e.Traditional != e.Simplified {
+			if t := levenshtein(s, e.Traditional); t < ld {
+				ld = t
+			}
+		}
+		if ld <= maxDist {
+			dist[e] = ld
+			results = append(results, e)
+		}
+	}
+
+	// sort by levenshtein distance, then closest length
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if dist[a] != dist[b] {
+			return dist[a] < dist[b]
+		}
+		return abs(utf8.RuneCountInString(a.Simplified)-n) <
+			abs(utf8.RuneCountInString(b.Simplified)-n)
+	})
+
+	// limit results returned
+	if len(results) > MaxResults {
+		results = results[:MaxResults]
+	}
+
+	return results
+}
+
+// GetAllByHanzi returns all Dict entries for the hanzi, in dictionary
+// order. Supports input using traditional or simplified characters.
+func (d *Dict) GetAllByHanzi(s string) []*Entry {
+	d = d.snapshot()
+	var results []*Entry
+	for _, id := range d.hanziIDs(s) {
+		results = append(results, d.e[id])
+	}
+	return results
+}
+
+// GroupedByHanzi returns all entries for the hanzi grouped into a single
+// record, with a reading for each distinct pinyin, or nil if not found.
+// Meanings of entries with identical pinyin are combined.
+func (d *Dict) GroupedByHanzi(s string) *GroupedEntry {
+	entries := d.GetAllByHanzi(s)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	g := &GroupedEntry{Hanzi: strings.TrimSpace(s)}
+	index := make(map[string]int)
+	for _, e := range entries {
+		i, ok := index[e.Pinyin]
+		if !ok {
+			i = len(g.Readings)
+			index[e.Pinyin] = i
+			g.Readings = append(g.Readings, Reading{Pinyin: e.Pinyin})
+		}
+		g.Readings[i].Meanings = append(g.Readings[i].Meanings, e.Meanings...)
+	}
+	return g
+}
+
+// MeasureWordsFor returns the classifier entries listed in the noun's
+// "CL:" meanings, i.e. 个 and 位 for 人, or nil if none are listed.
+// Classifiers not found in the Dict are skipped.
+func (d *Dict) MeasureWordsFor(noun string) []*Entry {
+	var results []*Entry
+	seen := make(map[*Entry]bool)
+	for _, e := range d.GetAllByHanzi(noun) {
+		for _, m := range e.Meanings {
+			if !strings.HasPrefix(m, "CL:") {
+				continue
+			}
+
+			// CL:個|个[ge4],位[wei4]
+			for _, ref := range strings.Split(strings.TrimPrefix(m, "CL:"), ",") {
+				cl := d.lookupRef(ref)
+				if cl != nil && !seen[cl] {
+					seen[cl] = true
+					results = append(results, cl)
+				}
+			}
+		}
+	}
+	return results
+}
+
+// lookupRef returns the entry for a reference to another word in the
+// CC-CEDICT format, i.e. "個|个[ge4]", preferring the entry with the
+// matching pinyin. It returns nil if no entry is found.
+func (d *Dict) lookupRef(ref string) *Entry {
+	hanzi, pinyin := ref, ""
+	if i := strings.Index(ref, "["); i >= 0 {
+		hanzi = ref[:i]
+		pinyin = strings.TrimSuffix(ref[i+1:], "]")
+	}
+	if i := strings.Index(hanzi, "|"); i >= 0 {
+		hanzi = hanzi[i+1:]
+	}
+
+	entries := d.GetAllByHanzi(hanzi)
+	for _, e := range entries {
+		if strings.EqualFold(e.Pinyin, pinyin) {
+			return e
+		}
+	}
+	if len(entries) > 0 {
+		return entries[0]
+	}
+	return nil
+}
+
+// PinyinOf returns the pinyin of the entry for the whole word,
+// without segmenting it, and false if the word is not found.
+func (d *Dict) PinyinOf(word string) (string, bool) {
+	e := d.GetByHanzi(word)
+	if e == nil {
+		return "", false
+	}
+	return e.Pinyin, true
+}
+
+// ReadingsOf returns the distinct pinyin readings of a single hanzi
+// character, in lowercase and sorted, i.e. 行 -> [hang2 xing2].
+func (d *Dict) ReadingsOf(char string) []string {
+	d = d.snapshot()
+	return d.readingsOf(strings.TrimSpace(char))
+}
+
+// readingsOf returns the distinct lowercase pinyin readings of the
+// hanzi character in the entries of the Dict, without taking a snapshot.
+func (d *Dict) readingsOf(char string) []string {
+	if len([]rune(char)) != 1 {
+		return nil
+	}
+
+	var readings []string
+	seen := make(map[string]bool)
+	for _, id := range d.hanzi[char] {
+		p := strings.ToLower(d.e[id].Pinyin)
+		if !seen[p] {
+			seen[p] = true
+			readings = append(readings, p)
+		}
+	}
+	sort.Strings(readings)
+	return readings
+}
+
+// Homophones returns single character entries sharing a pinyin reading
+// with the hanzi character, excluding the character itself. If matchTone
+// is false, readings with any tone are considered matching.
+func (d *Dict) Homophones(char string, matchTone bool) []*Entry {
+	d = d.snapshot()
+	char = strings.TrimSpace(char)
+
+	// collect readings of the input character
+	readings := make(map[string]bool)
+	for _, p := range d.readingsOf(char) {
+		if !matchTone {
+			p = StripDigits(p)
+		}
+		readings[p] = true
+	}
+	if len(readings) == 0 {
+		return nil
+	}
+
+	var results []*Entry
+	for _, e := range d.e {
+		if len([]rune(e.Simplified)) != 1 || e.Traditional == char || e.Simplified == char {
+			continue
+		}
 		p := strings.ToLower(e.Pinyin)
-		p = strings.ReplaceAll(p, " ", "")
+		if !matchTone {
+			p = StripDigits(p)
+		}
+		if readings[p] {
+			results = append(results, e)
+			if len(results) >= MaxResults {
+				break
+			}
+		}
+	}
+	return results
+}
+
+// ContainingChar returns multi-character entries containing the hanzi
+// character in their traditional or simplified form, shortest first.
+func (d *Dict) ContainingChar(char string) []*Entry {
+	char = strings.TrimSpace(char)
+	if len([]rune(char)) != 1 {
+		return nil
+	}
+	return d.findByHanzi(char, strings.Contains)
+}
+
+// WordsWithPrefix returns entries, other than the hanzi itself,
+// with traditional or simplified forms beginning with the hanzi.
+func (d *Dict) WordsWithPrefix(s string) []*Entry {
+	return d.findByHanzi(strings.TrimSpace(s), strings.HasPrefix)
+}
+
+// WordsWithSuffix returns entries, other than the hanzi itself,
+// with traditional or simplified forms ending with the hanzi.
+func (d *Dict) WordsWithSuffix(s string) []*Entry {
+	return d.findByHanzi(strings.TrimSpace(s), strings.HasSuffix)
+}
+
+// findByHanzi returns entries where match(hanzi, s) is true for their
+// traditional or simplified form, excluding exact matches of s. Results
+// are sorted by length, shortest first.
+func (d *Dict) findByHanzi(s string, match func(hanzi, s string) bool) []*Entry {
+	d = d.snapshot()
+	if s == "" {
+		return nil
+	}
+
+	var results []*Entry
+	for _, e := range d.e {
+		if e.Traditional == s || e.Simplified == s {
+			continue
+		}
+		if match(e.Traditional, s) || match(e.Simplified, s) {
+			results = append(results, e)
+		}
+	}
+
+	// sort by word length
+	sort.SliceStable(results, func(i, j int) bool {
+		return len([]rune(results[i].Simplified)) < len([]rune(results[j].Simplified))
+	})
+
+	// limit results returned
+	if len(results) > MaxResults {
+		results = results[:MaxResults]
+	}
+
+	return results
+}
+
+// Neighbors returns up to n entries before and after the entry, in the
+// pinyin collated order of SortByPinyinCollation, like browsing a paper
+// dictionary. Both are in collated order, so the nearest entry before is
+// last. The entry may be a copy of a Dict entry, i.e. from SafeDict, and
+// nil is returned if it isn't found in the Dict.
+func (d *Dict) Neighbors(e *Entry, n int) (before, after []*Entry) {
+	d = d.snapshot()
+	if e == nil || n <= 0 || d.collation == nil {
+		return nil, nil
+	}
+
+	// find the entry by identity, or by value for copies
+	c := d.collation.sorted(d.e)
+	i, ok := c.pos[e]
+	if !ok {
+		for _, id := range d.hanzi[e.Traditional] {
+			if d.e[id].Marshal() == e.Marshal() {
+				i, ok = c.pos[d.e[id]], true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	start := i - n
+	if start < 0 {
+		start = 0
+	}
+	end := i + 1 + n
+	if end > len(c.order) {
+		end = len(c.order)
+	}
+	before = append([]*Entry(nil), c.order[start:i]...)
+	after = append([]*Entry(nil), c.order[i+1:end]...)
+	return before, after
+}
+
+// GetByPinyin returns hanzi matching the given pinyin string.
+// Supports pinyin in plaintext or with tones/tone numbers.
+// With plaintext, all tone variations are considered matching.
+// The ToneWildcard '?' matches any tone, i.e. "zhong1 wen?".
+// Tone numbers separated from their syllable by spaces are supported,
+// i.e. "zhong 1 wen 2", see NormalizePinyin.
+func (d *Dict) GetByPinyin(s string) []*Entry {
+	d = d.snapshot()
+
+	// convert tones to tone numbers, reattaching separated tone numbers
+	s = PinyinToneNums(NormalizePinyin(s))
+	isPlaintext := strings.IndexAny(s, toneNums+string(ToneWildcard)) < 0
+
+	// normalise pinyin to lowercase, no spaces
+	s = pinyinKey(s)
+
+	var results []*Entry
+	for _, e := range d.e {
+
+		// normalise entry pinyin to lowercase, no spaces
+		p := pinyinKey(e.Pinyin)
 
 		// if input is plaintext, remove tone numbers from entry
 		if isPlaintext {
@@ -341,7 +1438,7 @@ func (d *Dict) GetByPinyin(s string) []*Entry {
 		}
 
 		// add matching pinyin entries
-		if p == s {
+		if matchPinyinKey(p, s) {
 			results = append(results, e)
 		}
 	}
@@ -353,40 +1450,395 @@ func (d *Dict) GetByPinyin(s string) []*Entry {
 	return results
 }
 
+// GetByPinyinGrouped returns hanzi matching the given pinyin string, with
+// all tone variations considered matching, grouped by their lowercase tone
+// number pinyin, i.e. "ma1", "ma2". This is useful for finding tone pairs.
+func (d *Dict) GetByPinyinGrouped(s string) map[string][]*Entry {
+	groups := make(map[string][]*Entry)
+	for _, e := range d.GetByPinyin(PinyinPlaintext(s)) {
+		p := strings.ToLower(e.Pinyin)
+		groups[p] = append(groups[p], e)
+	}
+	return groups
+}
+
+// GetByPinyinRanked returns hanzi matching the given pinyin string, with
+// all tone variations considered matching. If tones or tone numbers are
+// given, entries matching those tones exactly are ranked first.
+func (d *Dict) GetByPinyinRanked(s string) []*Entry {
+	results := d.GetByPinyin(PinyinPlaintext(s))
+
+	// rank exact tone matches first
+	key := pinyinKey(PinyinToneNums(s))
+	sort.SliceStable(results, func(i, j int) bool {
+		return pinyinKey(results[i].Pinyin) == key && pinyinKey(results[j].Pinyin) != key
+	})
+
+	return results
+}
+
+// SearchPinyinFuzzy returns entries with pinyin similar to the input,
+// which may be plaintext or use tones/tone numbers, i.e. "zhongwn".
+// Each syllable of the entry tolerates one typo, and if the input has
+// tones, mismatched tones lower the score rather than excluding the
+// entry. Results are sorted by score, highest first, limited to MaxResults.
+func (d *Dict) SearchPinyinFuzzy(s string) []ScoredEntry {
+	d = d.snapshot()
+
+	// split input into letters and tone numbers
+	letters := StripDigits(pinyinKey(PinyinPlaintext(s)))
+	tones := pinyinToneSequence(s)
+	if letters == "" {
+		return nil
+	}
+
+	var results []ScoredEntry
+	for _, e := range d.e {
+		p := pinyinKey(e.Pinyin)
+		n := len(pinyinSyllables(e.Pinyin))
+
+		// allow one edit per syllable
+		pl := StripDigits(p)
+		ld := levenshteinCapped(letters, pl, n)
+		if ld > n || ld >= len(pl) {
+			continue
+		}
+		score := 1 - float64(ld)/float64(len(pl))
+
+		// mismatched tones reduce the score by up to half
+		if tones != "" {
+			score *= 1 - 0.5*float64(toneMismatches(tones, pinyinToneSequence(p)))/float64(n)
+		}
+		if score > 0 {
+			results = append(results, ScoredEntry{Entry: e, Score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > MaxResults {
+		results = results[:MaxResults]
+	}
+	return results
+}
+
+// pinyinToneSequence returns the tone numbers of the pinyin in order,
+// from either tone marks or tone numbers, i.e. "zhōngwén" -> "12".
+func pinyinToneSequence(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFC.String(s) {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else if m := mapToneToNum[r]; m != "" && m[len(m)-1] != ' ' {
+			b.WriteByte(m[len(m)-1])
+		}
+	}
+	return b.String()
+}
+
+// toneMismatches returns the number of positions where both sequences
+// of tone numbers have a tone, and the tones differ.
+func toneMismatches(a, b string) int {
+	n := 0
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			n++
+		}
+	}
+	return n
+}
+
+// MeaningOption configures the behaviour of GetByMeaning.
+type MeaningOption func(*meaningOptions)
+
+// meaningOptions holds the settings applied by MeaningOption.
+type meaningOptions struct {
+	acrossMeanings bool
+}
+
+// AcrossMeanings also matches entries where every word of the query
+// appears in the entry's combined meanings, even if split across them,
+// i.e. "train station" matches /station/bullet train/. These matches
+// are returned after entries with a meaning matching the query.
+func AcrossMeanings() MeaningOption {
+	return func(o *meaningOptions) {
+		o.acrossMeanings = true
+	}
+}
+
 // GetByMeaning returns entries containing the specified meaning.
 // Matching is not case-sensitive and can be exact/non-exact.
-func (d *Dict) GetByMeaning(s string) []*Entry {
-	d.lazyLoad()
+// Queries shorter than the minimum length, see SetMinQueryLength,
+// return no entries.
+func (d *Dict) GetByMeaning(s string, opts ...MeaningOption) []*Entry {
+	var o meaningOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	d = d.snapshot()
+	matches := d.matchMeaning(s)
+	if o.acrossMeanings {
+		matches = d.matchAcrossMeanings(matches, s)
+	}
+
+	results := make([]*Entry, len(matches))
+	for i, m := range matches {
+		results[i] = m.e
+	}
+	return results
+}
+
+// GetByMeaningMatches returns entries containing the specified meaning,
+// as per GetByMeaning, along with the meaning which matched the query.
+func (d *Dict) GetByMeaningMatches(s string) []MeaningMatch {
+	matches := d.snapshot().matchMeaning(s)
+	results := make([]MeaningMatch, len(matches))
+	for i, m := range matches {
+		results[i] = MeaningMatch{Entry: m.e, Meaning: m.meaning}
+	}
+	return results
+}
+
+// matchMeaning returns the sorted and limited matches for GetByMeaning.
+func (d *Dict) matchMeaning(s string) []meaningMatch {
+
+	// reject queries which are too short
+	if utf8.RuneCountInString(strings.TrimSpace(s)) < d.minQuery {
+		return nil
+	}
 
 	// normalise input to lowercase
 	s = strings.ToLower(s)
 
-	var results []*Entry
-	lev := make(map[*Entry]int)
+	// skip entries which can't match, using the word index
+	entries := d.meaningCandidates(s)
+
+	// use parallel workers for many entries
+	workers := 1
+	if len(entries) >= parallelSearchMin {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	matches := matchMeanings(entries, s, workers)
+
+	// sort by levenshtein distance
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].ld < matches[j].ld
+	})
+
+	// limit results returned
+	if len(matches) > MaxResults {
+		matches = matches[:MaxResults]
+	}
+
+	return matches
+}
+
+// meaningMatch is an entry matching a GetByMeaning query, with its first
+// matching meaning and the levenshtein distance to the query.
+type meaningMatch struct {
+	e       *Entry
+	meaning string
+	ld      int
+}
+
+// matchAcrossMeanings appends entries where every word of the input
+// appears in the combined meanings to the matches, up to MaxResults.
+func (d *Dict) matchAcrossMeanings(matches []meaningMatch, s string) []meaningMatch {
+	query := uniqueWords(meaningWords(s))
+	if len(query) == 0 || utf8.RuneCountInString(strings.TrimSpace(s)) < d.minQuery {
+		return matches
+	}
+
+	matched := make(map[*Entry]bool)
+	for _, m := range matches {
+		matched[m.e] = true
+	}
+	for _, e := range lookupIndex(d.e, d.words, query, true) {
+		if len(matches) >= MaxResults {
+			break
+		}
+		if matched[e] {
+			continue
+		}
+
+		// every word must appear in some meaning
+		combined := strings.Join(e.Meanings, " ")
+		if countMatches(meaningWords(combined), query) == len(query) {
+			matches = append(matches, meaningMatch{e, combined, MaxLD + 1})
+		}
+	}
+	return matches
+}
+
+// matchMeanings returns entries with a meaning found in the lowercase
+// input, scanning a chunk of entries with each worker. Matches are
+// returned in entry order, regardless of the number of workers.
+func matchMeanings(entries []*Entry, s string, workers int) []meaningMatch {
+	chunks := make([][]meaningMatch, workers)
+	chunk := (len(entries) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo := w * chunk
+		hi := lo + chunk
+		if hi > len(entries) {
+			hi = len(entries)
+		}
+		if lo >= hi {
+			break
+		}
+
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			chunks[w] = matchMeaningRange(entries[lo:hi], s)
+		}(w, lo, hi)
+	}
+	wg.Wait()
+
+	// chunks are ordered, so appending keeps entry order
+	var matches []meaningMatch
+	for _, c := range chunks {
+		matches = append(matches, c...)
+	}
+	return matches
+}
+
+// matchMeaningRange returns entries with a meaning found in the
+// lowercase input, within the maximum levenshtein distance.
+func matchMeaningRange(entries []*Entry, s string) []meaningMatch {
+	var matches []meaningMatch
 nextEntry:
-	for _, e := range d.e {
-		for _, m := range e.Meanings {
+	for _, e := range entries {
+		for _, meaning := range e.Meanings {
 
 			// normalise entry to lowercase
-			m = strings.ToLower(m)
+			m := strings.ToLower(meaning)
 
 			// check if meaning matches
 			if strings.Contains(s, m) {
-				ld := levenshtein(s, m)
+				ld := levenshteinCapped(s, m, MaxLD)
 
 				// discard matches too far from input
 				if ld <= MaxLD {
-					lev[e] = ld
-					results = append(results, e)
+					matches = append(matches, meaningMatch{e, meaning, ld})
 					continue nextEntry
 				}
 			}
 		}
 	}
+	return matches
+}
 
-	// sort by levenshtein distance
+// GetByMeaningPOS returns entries containing the specified meaning, as
+// per GetByMeaning, which have the part of speech tag, i.e. "verb".
+func (d *Dict) GetByMeaningPOS(s, pos string) []*Entry {
+	var results []*Entry
+	for _, e := range d.GetByMeaning(s) {
+		for _, tag := range e.PartOfSpeech() {
+			if tag == pos {
+				results = append(results, e)
+				break
+			}
+		}
+	}
+	return results
+}
+
+// GetByMeaningStemmed returns entries with a meaning containing all words
+// of the input, comparing words by their stem so "running" matches "to run".
+// Results are sorted by the number of extra words in the matching meaning.
+func (d *Dict) GetByMeaningStemmed(s string) []*Entry {
+	d = d.snapshot()
+
+	// reduce input words to stems
+	query := stemWords(s)
+	if len(query) == 0 {
+		return nil
+	}
+
+	return matchMeaningStems(lookupIndex(d.e, d.stems, query, true), query)
+}
+
+// matchMeaningStems returns entries with a meaning containing all stems.
+func matchMeaningStems(entries []*Entry, query []string) []*Entry {
+	var results []*Entry
+	extra := make(map[*Entry]int)
+	for _, e := range entries {
+		for _, m := range e.Meanings {
+
+			// check if meaning contains all stems
+			words := stemWords(m)
+			if !containsAll(words, query) {
+				continue
+			}
+
+			// keep the closest meaning for each entry
+			n := len(words) - len(query)
+			if prev, ok := extra[e]; !ok {
+				results = append(results, e)
+				extra[e] = n
+			} else if n < prev {
+				extra[e] = n
+			}
+		}
+	}
+
+	// sort by extra words in meaning
+	sort.SliceStable(results, func(i, j int) bool {
+		return extra[results[i]] < extra[results[j]]
+	})
+
+	// limit results returned
+	if len(results) > MaxResults {
+		results = results[:MaxResults]
+	}
+
+	return results
+}
+
+// GetByMeaningWords returns entries with a meaning containing the words,
+// in any order. If matchAll is true every word must appear in the same
+// meaning, otherwise any word matches. Results are sorted by words matched.
+func (d *Dict) GetByMeaningWords(words []string, matchAll bool) []*Entry {
+	d = d.snapshot()
+
+	// normalise input to unique lowercase words
+	query := uniqueWords(meaningWords(strings.Join(words, " ")))
+	if len(query) == 0 {
+		return nil
+	}
+
+	return matchMeaningWords(lookupIndex(d.e, d.words, query, matchAll), query, matchAll)
+}
+
+// matchMeaningWords returns entries with a meaning containing the words.
+func matchMeaningWords(entries []*Entry, query []string, matchAll bool) []*Entry {
+	var results []*Entry
+	matched := make(map[*Entry]int)
+	for _, e := range entries {
+
+		// find the meaning matching the most words
+		best := 0
+		for _, m := range e.Meanings {
+			if n := countMatches(meaningWords(m), query); n > best {
+				best = n
+			}
+		}
+
+		// discard entries without enough matching words
+		if best == 0 || (matchAll && best < len(query)) {
+			continue
+		}
+		matched[e] = best
+		results = append(results, e)
+	}
+
+	// sort by number of words matched
 	sort.SliceStable(results, func(i, j int) bool {
-		return lev[results[i]] < lev[results[j]]
+		return matched[results[i]] > matched[results[j]]
 	})
 
 	// limit results returned
@@ -397,56 +1849,396 @@ nextEntry:
 	return results
 }
 
+// GetByMeaningQuery returns entries matching a search query, where words
+// in double quotes must appear as an exact phrase, and words prefixed with
+// '-' must not appear in any meaning, i.e. `"to go" -abroad`. All other
+// words must appear in the same meaning as the phrases, in any order.
+// Results are sorted by the number of extra words in the matching meaning.
+func (d *Dict) GetByMeaningQuery(q string) []*Entry {
+	d = d.snapshot()
+
+	// every word in the words and phrases must be indexed
+	words, phrases, excluded := parseMeaningQuery(q)
+	query := words
+	for _, p := range phrases {
+		query = append(query, meaningWords(p)...)
+	}
+	query = uniqueWords(query)
+	if len(query) == 0 {
+		return nil
+	}
+
+	var results []*Entry
+	extra := make(map[*Entry]int)
+nextEntry:
+	for _, e := range lookupIndex(d.e, d.words, query, true) {
+
+		// discard entries with excluded words in any meaning
+		for _, m := range e.Meanings {
+			if countMatches(meaningWords(m), excluded) > 0 {
+				continue nextEntry
+			}
+		}
+
+		// keep the closest meaning matching the words and phrases
+		best := -1
+		for _, m := range e.Meanings {
+			mw := meaningWords(m)
+			if !containsAll(mw, query) || !containsPhrases(strings.ToLower(m), phrases) {
+				continue
+			}
+			if n := len(mw) - len(query); best < 0 || n < best {
+				best = n
+			}
+		}
+		if best >= 0 {
+			extra[e] = best
+			results = append(results, e)
+		}
+	}
+
+	// sort by extra words in meaning
+	sort.SliceStable(results, func(i, j int) bool {
+		return extra[results[i]] < extra[results[j]]
+	})
+
+	// limit results returned
+	if len(results) > MaxResults {
+		results = results[:MaxResults]
+	}
+
+	return results
+}
+
+// parseMeaningQuery splits a search query into lowercase words, quoted
+// phrases and excluded words. An unterminated quote runs to the end.
+func parseMeaningQuery(q string) (words, phrases, excluded []string) {
+	q = strings.ToLower(q)
+	for len(q) > 0 {
+		switch {
+		case q[0] == '"':
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				end = len(q) - 1
+			}
+			if p := strings.Join(meaningWords(q[1:end+1]), " "); p != "" {
+				phrases = append(phrases, p)
+			}
+			if end+2 < len(q) {
+				q = q[end+2:]
+			} else {
+				q = ""
+			}
+
+		case unicode.IsSpace(rune(q[0])):
+			q = q[1:]
+
+		default:
+			end := strings.IndexAny(q, " \t\"")
+			if end < 0 {
+				end = len(q)
+			}
+			if strings.HasPrefix(q, "-") {
+				excluded = append(excluded, meaningWords(q[1:end])...)
+			} else {
+				words = append(words, meaningWords(q[:end])...)
+			}
+			q = q[end:]
+		}
+	}
+	return words, phrases, excluded
+}
+
+// containsPhrases returns true if the lowercase meaning contains every
+// phrase, comparing words separated by any non-letter characters.
+func containsPhrases(m string, phrases []string) bool {
+	m = " " + strings.Join(meaningWords(m), " ") + " "
+	for _, p := range phrases {
+		if !strings.Contains(m, " "+p+" ") {
+			return false
+		}
+	}
+	return true
+}
+
 // HanziToPinyin converts hanzi to their pinyin representation.
 // It implements greedy matching for longest character combos.
+// Proper nouns keep their capitals and are written as one word,
+// i.e. 北京 -> Bei3jing1, which PinyinTones converts to Běijīng.
 func (d *Dict) HanziToPinyin(s string) string {
-	d.lazyLoad()
+	p, _ := d.HanziToPinyinReport(s)
+	return p
+}
+
+// HanziToPinyinSentences converts hanzi to pinyin as per HanziToPinyin,
+// capitalizing the first letter of each sentence rather than only the
+// first letter of the output. Sentences end with a full stop, question
+// or exclamation mark, after conversion from hanzi symbols.
+func (d *Dict) HanziToPinyinSentences(s string) string {
+	runes := []rune(d.HanziToPinyin(s))
+	start := true
+	for i, r := range runes {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start {
+				runes[i] = unicode.ToUpper(r)
+				start = false
+			}
+
+		// punctuation within text, i.e. 3.5, doesn't end a sentence
+		case strings.ContainsRune(".?!", r):
+			start = i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+		}
+	}
+	return string(runes)
+}
+
+// HanziToPinyinReport converts hanzi to pinyin as per HanziToPinyin, also
+// returning the hanzi which were not found in the Dict and were added to
+// the output unconverted. Each unknown hanzi is reported once, in order.
+func (d *Dict) HanziToPinyinReport(s string) (string, []rune) {
+	return d.snapshot().hanziToPinyin(s, ConvertOptions{})
+}
+
+// ConvertOptions configures the conversion of hanzi by HanziToPinyinWith.
+type ConvertOptions struct {
+
+	// Tones writes pinyin with tone marks rather than tone numbers.
+	Tones bool
+
+	// JoinSyllables writes the syllables of each word without spaces, as
+	// per JoinSyllables, i.e. 中文 -> "Zhong1wen2", keeping spaces between
+	// words as found by Segment.
+	JoinSyllables bool
+
+	// PerChar looks up each character individually, as per
+	// HanziToPinyinPerChar, rather than the longest words.
+	PerChar bool
+
+	// Unknown sets how hanzi not found in the Dict are written,
+	// by default they are kept unconverted.
+	Unknown UnknownCharMode
+}
+
+// UnknownCharMode sets how hanzi not found in the Dict are written when
+// converting to pinyin. The zero value is UnknownCharKeep.
+type UnknownCharMode struct {
+	drop        bool
+	replace     bool
+	replacement string
+}
+
+var (
+	// UnknownCharKeep writes unknown hanzi unconverted.
+	UnknownCharKeep = UnknownCharMode{}
+
+	// UnknownCharDrop removes unknown hanzi from the output.
+	UnknownCharDrop = UnknownCharMode{drop: true}
+)
+
+// UnknownCharReplace writes the placeholder, i.e. "?", as a separate
+// word in place of each unknown hanzi.
+func UnknownCharReplace(placeholder string) UnknownCharMode {
+	return UnknownCharMode{replace: true, replacement: placeholder}
+}
+
+// HanziToPinyinWith converts hanzi to pinyin as per HanziToPinyin,
+// using the options, i.e. 中文 -> "Zhōngwén" with Tones and JoinSyllables.
+func (d *Dict) HanziToPinyinWith(s string, opts ConvertOptions) string {
+	p, _ := d.snapshot().hanziToPinyin(s, opts)
+	return p
+}
+
+// HanziToPinyinPerChar converts hanzi to pinyin as per HanziToPinyin, but
+// looks up each character individually rather than the longest words,
+// i.e. 银行 -> "Yin2 xing2" using the most common reading of 行, where
+// HanziToPinyin returns "Yin2 hang2". This is useful for annotating
+// individual characters.
+func (d *Dict) HanziToPinyinPerChar(s string) string {
+	p, _ := d.snapshot().hanziToPinyin(s, ConvertOptions{PerChar: true})
+	return p
+}
+
+// hanziToPinyin implements HanziToPinyinReport using the options.
+func (d *Dict) hanziToPinyin(s string, opts ConvertOptions) (string, []rune) {
+	p, unknown := d.pinyinWords(s, opts)
+
+	// capitalize the first rune, which may be a tone mark or hanzi
+	if p == "" {
+		return "", unknown
+	}
+	r, n := utf8.DecodeRuneInString(p)
+	return string(unicode.ToUpper(r)) + strings.TrimSpace(p[n:]), unknown
+}
+
+// pinyinWords converts hanzi to pinyin for hanziToPinyin, without
+// capitalizing the output, which may end with whitespace.
+func (d *Dict) pinyinWords(s string, opts ConvertOptions) (string, []rune) {
 
 	// handle early exit
 	s = strings.TrimSpace(s)
 	if len(s) == 0 {
-		return ""
+		return "", nil
 	}
 
 	// hanzi to latin symbols
 	s = ConvertSymbols(s)
 
-	// iterate through possible word combos
-	p := ""
-	runes := []rune(s)
+	// convert each word, unknown hanzi are added as-is
+	var sb strings.Builder
+	var unknown []rune
+	seen := make(map[rune]bool)
+	d.segment([]rune(s), opts.PerChar, func(seg []rune, e *Entry) {
+		sb.WriteString(segmentPinyin(seg, e, opts))
+		if e == nil && unicode.In(seg[0], unicode.Han) && !seen[seg[0]] {
+			seen[seg[0]] = true
+			unknown = append(unknown, seg[0])
+		}
+	})
+
+	return sb.String(), unknown
+}
+
+// segmentPinyin returns the text written by pinyinWords for a segment,
+// followed by a space unless it is unknown hanzi written as-is.
+func segmentPinyin(seg []rune, e *Entry, opts ConvertOptions) string {
+	switch {
+	case e != nil:
+		p := wordPinyin(e)
+		if opts.Tones {
+			p = PinyinTones(p)
+		}
+		if opts.JoinSyllables {
+			p = JoinSyllables(p)
+		}
+		return p + " "
+	case unicode.In(seg[0], unicode.Han):
+		switch {
+		case opts.Unknown.drop:
+			return ""
+		case opts.Unknown.replace:
+			return opts.Unknown.replacement + " "
+		default:
+			return string(seg)
+		}
+	default:
+		return strings.ToLower(string(seg)) + " "
+	}
+}
+
+// wordPinyin returns the pinyin of an entry as written by HanziToPinyin.
+// It is lowercase, unless the entry is a proper noun with multiple
+// syllables, as single syllable proper nouns are mostly surnames.
+// Proper nouns keep their capitals, with the syllables following each
+// capital joined into one word, i.e. Bei3jing1 or Mao2 Ze2dong1.
+func wordPinyin(e *Entry) string {
+	if e.IsProperNoun() && len(pinyinSyllables(e.Pinyin)) > 1 {
+		return joinProperNoun(e.Pinyin)
+	}
+	return strings.ToLower(e.Pinyin)
+}
+
+// joinProperNoun joins pinyin syllables onto the previous syllable,
+// unless they start with a capital, adding apostrophes where needed.
+// Symbols such as the middle dot in foreign names stay separate words.
+func joinProperNoun(p string) string {
+	var sb strings.Builder
+	prev := false
+	for i, w := range strings.Fields(p) {
+		r, _ := utf8.DecodeRuneInString(w)
+		letter := unicode.IsLetter(r)
+		switch {
+		case i == 0:
+		case !letter || !prev || unicode.IsUpper(r):
+			sb.WriteByte(' ')
+		case needsApostrophe(w):
+			sb.WriteByte('\'')
+		}
+		sb.WriteString(w)
+		prev = letter
+	}
+	return sb.String()
+}
+
+// Segment splits text into dictionary words, using greedy matching for
+// the longest words. Runs of non-hanzi characters are returned as single
+// segments, with surrounding whitespace removed.
+func (d *Dict) Segment(s string) []string {
+	d = d.snapshot()
+	var words []string
+	d.segment([]rune(s), false, func(seg []rune, e *Entry) {
+		if w := strings.TrimSpace(string(seg)); w != "" {
+			words = append(words, w)
+		}
+	})
+	return words
+}
+
+// segment splits runes into the longest matching words, calling fn for each
+// segment with its entry, or nil for unknown hanzi and runs of non-hanzi
+// characters. Whitespace following a matched word is skipped. Overrides
+// registered with SetReading are matched before the dictionary entries.
+// If perChar is true, each hanzi is matched as a single character word.
+func (d *Dict) segment(runes []rune, perChar bool, fn func(seg []rune, e *Entry)) {
 	for i := 0; i < len(runes); {
 
-		// skip non-hanzi characters
+		// group non-hanzi characters
 		if !unicode.In(runes[i], unicode.Han) {
-			for ; i < len(runes) && !unicode.In(runes[i], unicode.Han); i++ {
-				p += string(runes[i])
+			j := i + 1
+			for ; j < len(runes) && !unicode.In(runes[j], unicode.Han); j++ {
 			}
-			p += " "
+			fn(runes[i:j], nil)
+			i = j
 			continue
 		}
 
-		// try to match longest hanzi combo to entry
-		found := false
-		for j := len(runes); j > i; j-- {
-			han := string(runes[i:j])
-			e := d.GetByHanzi(han)
-			if e != nil {
-				i = j
-				found = true
-				p += e.Pinyin + " "
-				break
-			}
+		// match reading overrides, then longest hanzi combo to entry
+		word := runes[i:]
+		if perChar {
+			word = runes[i : i+1]
 		}
-
-		// we didn't find it, just add it as-is
-		if !found {
-			p += string(runes[i])
+		n, e := d.matchWord(word)
+		if e == nil {
+			fn(runes[i:i+1], nil)
 			i++
+			continue
+		}
+		fn(runes[i:i+n], e)
+		for i += n; i < len(runes) && unicode.IsSpace(runes[i]); i++ {
 		}
 	}
+}
+
+// matchWord returns the length in runes of the longest word prefixing the
+// runes and its entry, matching reading overrides first, or zero and nil.
+func (d *Dict) matchWord(word []rune) (int, *Entry) {
+	if n, e := d.readings.longest(word); e != nil {
+		return n, e
+	}
+	n, id := d.trie.longest(word)
+	if id < 0 {
+		return 0, nil
+	}
 
-	// todo: check how this interacts with uppercase tones?
-	return strings.ToUpper(p[:1]) + strings.ToLower(strings.TrimSpace(p[1:]))
+	// prefer the most frequent reading of heteronyms, which is
+	// kept out of the trie so script conversion is unaffected
+	if _, ok := commonReadings[string(word[:n])]; ok {
+		id = d.preferredID(string(word[:n]))
+	}
+	return n, d.e[id]
+}
+
+// maxWordLen returns the length in runes of the longest word which may be
+// matched by segment, including reading overrides.
+func (d *Dict) maxWordLen() int {
+	n := 0
+	if d.trie != nil {
+		n = d.trie.depth
+	}
+	if d.readings != nil && d.readings.trie.depth > n {
+		n = d.readings.trie.depth
+	}
+	return n
 }
 
 // lazyLoad is used as a blocking barrier to ensure methods
@@ -456,13 +2248,21 @@ func (d *Dict) lazyLoad() {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	if !d.isReady() {
+		defer d.setDone()
 
 		// download latest CC-CEDICT
-		r, err := Download()
+		open := d.open
+		if open == nil {
+			open = func() (io.ReadCloser, error) {
+				return Download()
+			}
+		}
+		r, err := open()
 		if err != nil {
 			d.err = errors.WithStack(err)
 			return
 		}
+		defer r.Close()
 
 		// parse metadata + entries
 		dict, err := Parse(r)
@@ -472,13 +2272,144 @@ func (d *Dict) lazyLoad() {
 		}
 
 		// populate dict
-		d.e = dict.e
-		d.md = dict.md
-		d.header = dict.header
+		d.replace(dict)
+	}
+}
+
+// snapshot blocks until the Dict is populated, then returns a copy of
+// its fields taken under the mutex, so that methods see consistent
+// entries and indexes even if the Dict is reloaded concurrently.
+func (d *Dict) snapshot() *Dict {
+	d.lazyLoad()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return &Dict{
+		e:      d.e,
+		md:     d.md,
+		ready:  d.ready,
+		done:   d.done,
+		header: d.header,
+		notes:  d.notes,
+		err:    d.err,
+		words:  d.words,
+		stems:  d.stems,
+		hanzi:  d.hanzi,
+		folded: d.folded,
+		trie:   d.trie,
+		toSimp: d.toSimp,
+		toTrad: d.toTrad,
+
+		collation: d.collation,
+		readings:  d.readings,
+		minQuery:  d.minQuery,
+		wordLen:   d.wordLen,
+	}
+}
+
+// replace populates the Dict with the fields of a parsed dict and
+// unblocks methods. The caller must hold the mutex.
+func (d *Dict) replace(dict *Dict) {
+	d.e = dict.e
+	d.md = dict.md
+	d.header = dict.header
+	d.notes = dict.notes
+	d.words = dict.words
+	d.stems = dict.stems
+	d.hanzi = dict.hanzi
+	d.folded = dict.folded
+	d.trie = dict.trie
+	d.toSimp = dict.toSimp
+	d.toTrad = dict.toTrad
+	d.collation = dict.collation
+	d.wordLen = dict.wordLen
+	d.err = nil
+	d.setReady()
+}
+
+// Reload downloads and parses the latest CC-CEDICT, then swaps it in
+// place of the current entries. Methods called concurrently will use
+// either the old or new entries, and the current entries are kept if
+// the reload fails.
+func (d *Dict) Reload() error {
+	return d.reload(func() (io.ReadCloser, error) {
+		return Download()
+	})
+}
+
+// reload replaces the Dict with one parsed from the opened reader.
+func (d *Dict) reload(open func() (io.ReadCloser, error)) error {
+	r, err := open()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer r.Close()
+
+	// parse before locking, so methods aren't blocked
+	dict, err := Parse(r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.replace(dict)
+	return nil
+}
+
+// replaceMetadataHeader returns a copy of the header comments with the
+// lines for known metadata keys replaced by those for the metadata, at
+// the position of the first replaced line or at the end.
+func replaceMetadataHeader(header []string, md Metadata) []string {
+	var result []string
+	insert := -1
+	for _, line := range header {
+		if isMetadataLine(line) {
+			if insert < 0 {
+				insert = len(result)
+			}
+			continue
+		}
+		result = append(result, line)
+	}
+	if insert < 0 {
+		insert = len(result)
+	}
+	lines := metadataHeader(md)
+	return append(result[:insert], append(lines, result[insert:]...)...)
+}
+
+// isMetadataLine returns true if the header comment line sets the value
+// of a metadata key parsed by parseMetadata, i.e. "#! version=1".
+func isMetadataLine(line string) bool {
+	i := strings.Index(line, "=")
+	if !strings.HasPrefix(line, "#! ") || i < 0 {
+		return false
+	}
+	switch line[3:i] {
+	case "version", "subversion", "format", "charset", "entries",
+		"publisher", "license", "date", "time":
+		return true
+	}
+	return false
+}
 
-		// unblock methods
-		d.setReady()
+// setHeaderValue returns a copy of the header comments with the
+// metadata key set to the value, appending it if not yet present.
+func setHeaderValue(header []string, key, value string) []string {
+	prefix := "#! " + key + "="
+	result := make([]string, 0, len(header)+1)
+	found := false
+	for _, line := range header {
+		if strings.HasPrefix(line, prefix) {
+			line = prefix + value
+			found = true
+		}
+		result = append(result, line)
 	}
+	if !found {
+		result = append(result, prefix+value)
+	}
+	return result
 }
 
 // isReady returns true if Dict is populated
@@ -496,14 +2427,88 @@ func (d *Dict) setReady() {
 	if !d.isReady() {
 		close(d.ready)
 	}
+	d.setDone()
+}
+
+// setDone closes the channel returned by Done
+func (d *Dict) setDone() {
+	select {
+	case <-d.done:
+	default:
+		close(d.done)
+	}
+}
+
+// Clone returns a deep copy of the entry, which can be safely modified
+// without affecting the Dict it was returned from.
+func (e *Entry) Clone() *Entry {
+	c := *e
+	c.Meanings = append([]string(nil), e.Meanings...)
+	return &c
+}
+
+// IsProperNoun returns true if the entry is likely a proper noun, such as
+// a place or person's name, as CC-CEDICT capitalizes their pinyin.
+func (e *Entry) IsProperNoun() bool {
+	r, _ := utf8.DecodeRuneInString(e.Pinyin)
+	return unicode.IsUpper(r)
+}
+
+// PartOfSpeech returns rough part of speech tags for the entry, inferred
+// from markers in its meanings, i.e. "verb" for "to run" or "idiom" for
+// meanings containing "(idiom)". Tags are returned in a consistent order.
+func (e *Entry) PartOfSpeech() []string {
+	var tags []string
+	for _, pm := range posMarkers {
+		for _, m := range e.Meanings {
+			if pm.match(m) {
+				tags = append(tags, pm.tag)
+				break
+			}
+		}
+	}
+	return tags
+}
+
+// CharPinyin returns each character of the entry's simplified hanzi with
+// its syllable of the entry's pinyin, i.e. 中国 -> 中 "Zhong1", 国 "guo2".
+// If the number of syllables doesn't match the number of characters, they
+// are aligned in order, characters without a syllable have empty pinyin
+// and any extra syllables are added to the pinyin of the last character.
+func (e *Entry) CharPinyin() []CharSyllable {
+	chars, _ := alignPinyin(e.Simplified, e.Pinyin)
+	return chars
+}
+
+// alignPinyin returns the characters of the hanzi aligned with syllables
+// of the CC-CEDICT formatted pinyin, as per CharPinyin, and true if the
+// number of characters and syllables matched.
+func alignPinyin(hanzi, pinyin string) ([]CharSyllable, bool) {
+	runes := []rune(hanzi)
+	syllables := pinyinSyllables(pinyin)
+	chars := make([]CharSyllable, len(runes))
+	for i, r := range runes {
+		chars[i].Char = string(r)
+		if i < len(syllables) {
+			chars[i].Pinyin = syllables[i]
+		}
+	}
+
+	// extra syllables are added to the last character
+	if n := len(runes); n > 0 && len(syllables) > n {
+		chars[n-1].Pinyin = strings.Join(syllables[n-1:], " ")
+	}
+	return chars, len(runes) == len(syllables)
 }
 
 // Marshal returns the entry, formatted according to
 // https://cc-cedict.org/wiki/format:syntax
 func (e *Entry) Marshal() string {
+
+	// meanings with slashes are still joined, see Validate
+	meanings, _ := JoinMeanings(e.Meanings)
 	return fmt.Sprintf("%s %s [%s] %s",
-		e.Traditional, e.Simplified, e.Pinyin,
-		"/"+strings.Join(e.Meanings, "/")+"/",
+		e.Traditional, e.Simplified, e.Pinyin, meanings,
 	)
 }
 
@@ -511,34 +2516,147 @@ func (e *Entry) Marshal() string {
 // according to https://cc-cedict.org/wiki/format:syntax
 func (e *Entry) Unmarshal(s string) error {
 
-	// parse pinyin and meanings
-	fields := strings.Split(s, "/")
-	off := strings.Index(fields[0], "[")
-	end := strings.Index(fields[0], "]")
-	if off < 0 || end < 0 {
+	// 龍豆 龙豆 [long2 dou4] /dragon bean/long bean/
+	// split the leading fields on whitespace, so hanzi containing
+	// brackets or slashes don't confuse locating the pinyin
+	var hanzi []string
+	off := -1
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] != ' ' && s[j] != '\t' {
+			j++
+		}
+
+		// pinyin is the first field after the hanzi starting with '['
+		if len(hanzi) >= 2 && s[i] == '[' {
+			off = i
+			break
+		}
+
+		// stop searching once the meanings are reached
+		if len(hanzi) >= 2 && s[i] == '/' {
+			break
+		}
+		hanzi = append(hanzi, s[i:j])
+		i = j
+	}
+	if off < 0 {
+		if len(hanzi) == 2 && strings.HasPrefix(hanzi[1], "[") {
+			return errors.New("expected two hanzi fields i.e. '龍豆 龙豆 '")
+		}
 		return errors.New("expected '[pinyin]' format")
 	}
-	chars := fields[0][:off]
-	pinyin := fields[0][off+1 : end]
 
-	// 龍豆 龙豆 [long2 dou4] /dragon bean/long bean/
-	var trad, sim string
-	n, err := fmt.Sscanf(chars, "%s %s ", &trad, &sim)
-	if err != nil {
-		return errors.WithStack(err)
-	} else if n != 2 {
+	// parse pinyin up to the closing bracket
+	end := strings.Index(s[off:], "]")
+	if end < 0 || strings.Contains(s[off:off+end], "/") {
+		return errors.New("expected '[pinyin]' format")
+	}
+	pinyin := s[off+1 : off+end]
+
+	// parse meanings following the pinyin, between the first and last slash
+	rest := s[off+end+1:]
+	first, last := strings.Index(rest, "/"), strings.LastIndex(rest, "/")
+	if first == last {
+		return errors.New("expected '/meanings/' format")
+	}
+	meanings := SplitMeanings(rest[first : last+1])
+	if len(meanings) == 0 {
+		return errors.New("expected '/meanings/' format")
+	}
+
+	if len(hanzi) != 2 {
 		return errors.New("expected two hanzi fields i.e. '龍豆 龙豆 '")
 	}
 
 	// set entry data
-	e.Traditional = trad
-	e.Simplified = sim
+	e.Traditional = hanzi[0]
+	e.Simplified = hanzi[1]
 	e.Pinyin = pinyin
-	e.Meanings = fields[1 : len(fields)-1]
+	e.Meanings = meanings
+
+	return nil
+}
+
+// SplitMeanings splits a CC-CEDICT meanings field, i.e. "/a/b/", into its
+// meanings. The surrounding slashes are optional, and empty meanings from
+// repeated slashes are ignored, i.e. "//a//b" -> [a b].
+func SplitMeanings(field string) []string {
+	var meanings []string
+	for _, m := range strings.Split(strings.TrimSpace(field), "/") {
+		if strings.TrimSpace(m) != "" {
+			meanings = append(meanings, m)
+		}
+	}
+	return meanings
+}
+
+// JoinMeanings joins meanings into a CC-CEDICT meanings field, i.e.
+// [a b] -> "/a/b/". An error is returned if any meaning contains a slash,
+// as it would be split when parsed, along with the joined field.
+func JoinMeanings(meanings []string) (string, error) {
+	field := "/" + strings.Join(meanings, "/") + "/"
+	for _, m := range meanings {
+		if strings.Contains(m, "/") {
+			return field, fmt.Errorf("unexpected '/' in meaning '%s'", m)
+		}
+	}
+	return field, nil
+}
 
+// Validate returns an error if the entry is not well-formed, i.e.
+// non-hanzi characters, mismatched pinyin syllables or no meanings.
+func (e *Entry) Validate() error {
+	if e.Traditional == "" || e.Simplified == "" {
+		return errors.New("expected traditional and simplified hanzi")
+	}
+	if !IsHanzi(e.Traditional) || !IsHanzi(e.Simplified) {
+		return errors.New("expected hanzi characters only")
+	}
+	if e.Pinyin == "" {
+		return errors.New("expected pinyin")
+	}
+	if err := e.validateSyllables(); err != nil {
+		return err
+	}
+	if len(e.Meanings) == 0 {
+		return errors.New("expected at least one meaning")
+	}
+	for _, m := range e.Meanings {
+		if strings.TrimSpace(m) == "" {
+			return errors.New("expected non-empty meanings")
+		}
+	}
+	if _, err := JoinMeanings(e.Meanings); err != nil {
+		return err
+	}
 	return nil
 }
 
+// validateSyllables returns an error if the number of pinyin
+// syllables doesn't match the number of hanzi characters.
+func (e *Entry) validateSyllables() error {
+	trad := len([]rune(e.Traditional))
+	simp := len([]rune(e.Simplified))
+	if trad != simp {
+		return fmt.Errorf("traditional characters (%d) != simplified characters (%d)",
+			trad, simp)
+	}
+	if n := len(pinyinSyllables(e.Pinyin)); n != simp {
+		return fmt.Errorf("pinyin syllables (%d) != hanzi characters (%d)", n, simp)
+	}
+	return nil
+}
+
+// pinyinSyllables splits CC-CEDICT formatted pinyin into syllables.
+func pinyinSyllables(s string) []string {
+	return strings.Fields(s)
+}
+
 // IsHanzi returns true if the string contains only han characters.
 // http://www.unicode.org/reports/tr38/tr38-27.html HAN Unification
 func IsHanzi(s string) bool {
@@ -566,6 +2684,8 @@ func StripTones(s string) string {
 }
 
 // ConvertSymbols replaces common hanzi symbols with latin symbols.
+// Middle dots separating the parts of foreign names, i.e. 比尔・盖茨,
+// are replaced with the interpunct "·" used by CC-CEDICT.
 func ConvertSymbols(s string) string {
 	result := ""
 	for _, r := range []rune(s) {
@@ -584,66 +2704,255 @@ func PinyinPlaintext(s string) string {
 }
 
 // PinyinToneNums returns pinyin string converting tones to tone numbers.
+// The tone number is written at the end of each syllable, where words
+// with several tone marks are split into syllables i.e. Běijīng ->
+// Bei3jing1, and merged erhua is split from its syllable i.e. wánr ->
+// wan2 r5.
+//
+// For pinyin in the canonical CC-CEDICT form, with space separated
+// syllables, tones 1-4 and ü written as u:, this is the inverse of
+// PinyinTones. The neutral tone has no tone mark, so the tone number 5
+// is dropped from syllables with vowels, i.e. ma5 -> ma -> ma.
 func PinyinToneNums(s string) string {
+	s = normalizeUmlaut(s)
 	result := ""
 	for _, w := range strings.Split(s, " ") {
-		tone := ""
-		for _, r := range w {
-			m := mapToneToNum[r]
-			if m != "" {
-				result += m[:len(m)-1]
-				tone = strings.TrimSpace(m[len(m)-1:])
-			} else {
-				result += string(r)
+
+		// convert joined syllables separately i.e. Běijīng or Xī'ān
+		parts := strings.Split(w, "'")
+		for i, p := range parts {
+			word := ""
+			for _, syllable := range splitToneMarks(p) {
+				word += syllableToneNums(syllable)
 			}
+			parts[i] = word
 		}
-		result += tone + " "
+		result += strings.Join(parts, "'") + " "
 	}
 	return strings.TrimSpace(result)
 }
 
+// syllableToneNums converts the tone mark of a single syllable to a tone
+// number written at its end, splitting merged erhua from the syllable.
+func syllableToneNums(w string) string {
+	word, tone := "", ""
+	for _, r := range w {
+		m := mapToneToNum[r]
+		if m != "" {
+			word += m[:len(m)-1]
+			if t := strings.TrimSpace(m[len(m)-1:]); t != "" {
+				tone = t
+			}
+		} else {
+			word += string(r)
+		}
+	}
+
+	// split erhua from the syllable i.e. wánr -> wan2 r5
+	if tone != "" && isErhua(word) {
+		word = word[:len(word)-1] + tone + " r"
+		tone = "5"
+	}
+	return word + tone
+}
+
+// splitToneMarks splits a word with more than one tone mark into its
+// syllables, i.e. Běijīng -> Běi, jīng. Words with one tone mark, or
+// which aren't valid pinyin syllables, are returned as-is.
+func splitToneMarks(w string) []string {
+	runes := []rune(w)
+	keys := make([]string, len(runes))
+	n := 0
+	for i, r := range runes {
+		keys[i] = string(r)
+		if m := mapToneToNum[r]; m != "" {
+			keys[i] = m[:len(m)-1]
+			if m[len(m)-1] != ' ' {
+				n++
+			}
+		}
+		keys[i] = strings.ReplaceAll(strings.ToLower(keys[i]), "u:", "v")
+	}
+	if n < 2 {
+		return []string{w}
+	}
+	parts := splitSyllables(strings.Join(keys, ""))
+	if parts == nil {
+		return []string{w}
+	}
+
+	// map the syllables back onto the runes of the word
+	var syllables []string
+	i := 0
+	for _, p := range parts {
+		j, key := i, ""
+		for ; j < len(runes) && len(key) < len(p); j++ {
+			key += keys[j]
+		}
+		syllables = append(syllables, string(runes[i:j]))
+		i = j
+	}
+	return syllables
+}
+
+// normalizeUmlaut returns pinyin with ü and its ASCII fallbacks written as
+// u:, as per CC-CEDICT. The fallbacks "v" and "uu", if DoubleUFallback is
+// enabled, are only converted after l or n, i.e. lv4 -> lu:4, as ü is
+// otherwise written as u and v isn't used, and only in words which are
+// then a valid syllable, so letters such as the V in "V C D" and english
+// words such as "silver" are left unchanged. Tone marked ü is left
+// unchanged.
+func normalizeUmlaut(s string) string {
+	var sb strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+
+		// words end at tone numbers, spaces and symbols
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || runes[j] == ':') {
+			j++
+		}
+		if j == i {
+			sb.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		plain, fallback := normalizeUmlautWord(runes[i:j], false), normalizeUmlautWord(runes[i:j], true)
+		syllable := strings.ReplaceAll(strings.ToLower(StripTones(fallback)), "u:", "v")
+		if fallback != plain && validSyllables[syllable] {
+			sb.WriteString(fallback)
+		} else {
+			sb.WriteString(plain)
+		}
+		i = j
+	}
+	return sb.String()
+}
+
+// normalizeUmlautWord returns the word with ü written as u:, converting
+// the ASCII fallbacks after l or n if fallbacks is true.
+func normalizeUmlautWord(word []rune, fallbacks bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(word); i++ {
+		r := word[i]
+		afterLN := fallbacks && i > 0 && strings.ContainsRune("lLnN", word[i-1])
+		switch {
+		case r == 'ü' || (afterLN && r == 'v'):
+			sb.WriteString("u:")
+		case r == 'Ü' || (afterLN && r == 'V'):
+			sb.WriteString("U:")
+		case afterLN && DoubleUFallback && (r == 'u' || r == 'U') &&
+			i+1 < len(word) && (word[i+1] == 'u' || word[i+1] == 'U'):
+			sb.WriteRune(r)
+			sb.WriteByte(':')
+			i++
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// isErhua returns true if the word is a single syllable with "r" merged
+// onto the end, where it isn't part of the syllable itself i.e. wanr.
+func isErhua(w string) bool {
+	if !strings.HasSuffix(w, "r") {
+		return false
+	}
+	w = strings.ReplaceAll(strings.ToLower(w), "u:", "v")
+	return validSyllables[w[:len(w)-1]] && !validSyllables[w]
+}
+
 // PinyinTones returns pinyin string converting tone numbers to tones.
 // It supports both CC-CEDICT format, with tones at the end of syllables
-// i.e. Zhong1 wen2, as well as inline format with tones after their
-// respective character i.e. Zho1ng we2n.
+// i.e. Zhong1 wen2 or Bei3jing1, as well as inline format with tones
+// after their respective character i.e. Zho1ng we2n. Erhua is merged onto the
+// previous syllable, i.e. wan2 r5 -> wánr, see PinyinToneNums.
 func PinyinTones(s string) string {
 
-	// convert u: into single rune ü
-	s = strings.ReplaceAll(s, "u:", "ü")
+	// convert u: and other fallbacks into single rune ü
+	s = strings.NewReplacer("u:", "ü", "U:", "Ü").Replace(normalizeUmlaut(s))
 
 	result := ""
 	for _, w := range strings.Split(s, " ") {
 
-		// find rune to apply tone to
-		i := guessToneIndex(w)
-
-		if i < 0 {
-			result += w + " "
+		// merge erhua onto the previous syllable i.e. na3 r5 -> nǎr
+		if w == "r5" && isErhuaBase(result) {
+			result = strings.TrimSuffix(result, " ") + "r "
 			continue
 		}
 
-		// todo: does this need to be done
-		numIndex := strings.IndexAny(w, toneNums)
-		if numIndex < 0 {
-			result += w + " "
-			continue
+		// convert joined syllables separately i.e. Bei3jing1 or Xi1'an1
+		for _, p := range splitToneSyllables(w) {
+			result += syllableTones(p)
 		}
+		result += " "
+	}
+	return strings.TrimSpace(result)
+}
 
-		tone, _ := strconv.Atoi(string(w[numIndex]))
-		tone--
-		if tone < 0 || tone >= len(mapNumToTone) {
-			result += w + " "
-			continue
+// syllableTones converts the tone number of a single syllable to a tone
+// mark, returning the syllable as-is if it has no valid tone number.
+func syllableTones(w string) string {
+
+	// find rune to apply tone to
+	i := guessToneIndex(w)
+	if i < 0 {
+		return w
+	}
+
+	numIndex := strings.IndexAny(w, toneNums)
+	if numIndex < 0 {
+		return w
+	}
+
+	tone, _ := strconv.Atoi(string(w[numIndex]))
+	tone--
+	if tone < 0 || tone >= len(mapNumToTone) {
+		return w
+	}
+
+	w = w[:numIndex] + w[numIndex+1:]
+	runes := []rune(w)
+	k := runes[i]
+	return string(runes[:i]) + string(mapNumToTone[k][tone]) + string(runes[i+1:])
+}
+
+// splitToneSyllables splits a word with more than one tone number after
+// each tone number, i.e. Bei3jing1 -> Bei3, jing1. Words with one tone
+// number are returned as-is, to support the inline format i.e. Zho1ng.
+func splitToneSyllables(w string) []string {
+	n := 0
+	for _, r := range w {
+		if strings.ContainsRune(toneNums, r) {
+			n++
+		}
+	}
+	if n < 2 {
+		return []string{w}
+	}
+	var syllables []string
+	for w != "" {
+		i := strings.IndexAny(w, toneNums) + 1
+		if strings.IndexAny(w[i:], toneNums) < 0 {
+			i = len(w)
 		}
+		syllables = append(syllables, w[:i])
+		w = w[i:]
+	}
+	return syllables
+}
 
-		w = w[:numIndex] + w[numIndex+1:]
-		runes := []rune(w)
-		k := runes[i]
-		result += string(runes[:i])
-		result += string(mapNumToTone[k][tone])
-		result += string(runes[i+1:]) + " "
+// isErhuaBase returns true if the converted pinyin ends with
+// a syllable that an erhua "r5" can be merged onto.
+func isErhuaBase(s string) bool {
+	s = strings.TrimSuffix(s, " ")
+	if s == "" {
+		return false
 	}
-	return strings.TrimSpace(result)
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return unicode.IsLetter(r)
 }
 
 // FixSymbolSpaces removes spaces added by HanziToPinyin
@@ -675,6 +2984,33 @@ func guessToneIndex(s string) int {
 	return -1
 }
 
+// pinyinKey normalises pinyin to lowercase with no spaces,
+// so that it can be compared regardless of syllable spacing.
+func pinyinKey(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), " ", "")
+}
+
+// matchPinyinKey returns true if the normalised entry pinyin matches
+// the input, where a ToneWildcard in the input matches any tone number.
+func matchPinyinKey(p, s string) bool {
+	if !strings.ContainsRune(s, ToneWildcard) {
+		return p == s
+	}
+	if len(p) != len(s) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == ToneWildcard {
+			if strings.IndexByte(toneNums, p[i]) < 0 {
+				return false
+			}
+		} else if p[i] != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // levenshtein calculates the Levenshtein distance (LD), which is a measure
 // of the similarity between two strings. The distance is the number of
 // deletions, insertions, or substitutions required to transform s1 into s2.
@@ -714,6 +3050,119 @@ func levenshtein(src, dst string) int {
 	return ld[l1]
 }
 
+// levenshteinCapped calculates the Levenshtein distance as per levenshtein,
+// returning limit+1 as soon as the distance is known to exceed the limit.
+// Only cells within limit of the diagonal are computed, as any path
+// leaving the band costs more than the limit.
+func levenshteinCapped(src, dst string, limit int) int {
+	if src == dst {
+		return 0
+	}
+	s1 := []rune(src)
+	s2 := []rune(dst)
+	if len(s1) > len(s2) {
+		s1, s2 = s2, s1
+	}
+	l1 := len(s1)
+	l2 := len(s2)
+	over := limit + 1
+	if l2-l1 > limit {
+		return over
+	}
+
+	// cells outside the band are treated as over the limit
+	prev := make([]int, l1+1)
+	curr := make([]int, l1+1)
+	for j := range prev {
+		prev[j] = j
+		if j > limit {
+			prev[j] = over
+		}
+	}
+	for i := 1; i <= l2; i++ {
+		lo, hi := i-limit, i+limit
+		if lo < 1 {
+			lo = 1
+		}
+		if hi > l1 {
+			hi = l1
+		}
+
+		// the first column, or the cell left of the band
+		curr[lo-1] = over
+		if lo == 1 && i <= limit {
+			curr[0] = i
+		}
+
+		best := curr[lo-1]
+		for j := lo; j <= hi; j++ {
+			cost := 1
+			if s2[i-1] == s1[j-1] {
+				cost = 0
+			}
+			d := min(prev[j-1]+cost, prev[j]+1, curr[j-1]+1)
+			if d > over {
+				d = over
+			}
+			curr[j] = d
+			if d < best {
+				best = d
+			}
+		}
+		if hi < l1 {
+			curr[hi+1] = over
+		}
+
+		// stop once every path exceeds the limit
+		if best > limit {
+			return over
+		}
+		prev, curr = curr, prev
+	}
+	return prev[l1]
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// containsAll returns true if every word in sub is present in words.
+func containsAll(words, sub []string) bool {
+	return countMatches(words, sub) == len(sub)
+}
+
+// countMatches returns the number of words in sub present in words.
+func countMatches(words, sub []string) int {
+	n := 0
+nextWord:
+	for _, s := range sub {
+		for _, w := range words {
+			if w == s {
+				n++
+				continue nextWord
+			}
+		}
+	}
+	return n
+}
+
+// uniqueWords returns the words with duplicates removed, preserving order.
+func uniqueWords(words []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
 // min returns the minimum of three int inputs
 func min(x, y, z int) int {
 	if x < y {
@@ -726,7 +3175,7 @@ func min(x, y, z int) int {
 	return z
 }
 
-var vowels = "AaEeiOouür"
+var vowels = "AaEeIiOoUuÜür"
 
 var toneNums = "12345"
 
@@ -735,10 +3184,13 @@ var mapNumToTone = map[rune][]rune{
 	'a': []rune("āáǎàa"),
 	'E': []rune("ĒÉĚÈE"),
 	'e': []rune("ēéěèe"),
+	'I': []rune("ĪÍǏÌI"),
 	'i': []rune("īíǐìi"),
 	'O': []rune("ŌÓǑÒO"),
 	'o': []rune("ōóǒòo"),
+	'U': []rune("ŪÚǓÙU"),
 	'u': []rune("ūúǔùu"),
+	'Ü': []rune("ǕǗǙǛÜ"),
 	'ü': []rune("ǖǘǚǜü"),
 	'r': []rune("rrrrr"),
 }
@@ -760,6 +3212,10 @@ var mapToneToNum = map[rune]string{
 	'é': "e2",
 	'ě': "e3",
 	'è': "e4",
+	'Ī': "I1",
+	'Í': "I2",
+	'Ǐ': "I3",
+	'Ì': "I4",
 	'ī': "i1",
 	'í': "i2",
 	'ǐ': "i3",
@@ -772,10 +3228,19 @@ var mapToneToNum = map[rune]string{
 	'ó': "o2",
 	'ǒ': "o3",
 	'ò': "o4",
+	'Ū': "U1",
+	'Ú': "U2",
+	'Ǔ': "U3",
+	'Ù': "U4",
 	'ū': "u1",
 	'ú': "u2",
 	'ǔ': "u3",
 	'ù': "u4",
+	'Ü': "U: ",
+	'Ǖ': "U:1",
+	'Ǘ': "U:2",
+	'Ǚ': "U:3",
+	'Ǜ': "U:4",
 	'ü': "u: ",
 	'ǖ': "u:1",
 	'ǘ': "u:2",
@@ -783,12 +3248,41 @@ var mapToneToNum = map[rune]string{
 	'ǜ': "u:4",
 }
 
+// posMarker is a meaning marker which indicates a part of speech.
+type posMarker struct {
+	tag    string
+	marker string
+	prefix bool
+}
+
+// match returns true if the meaning includes the marker.
+func (pm posMarker) match(m string) bool {
+	if pm.prefix {
+		return strings.HasPrefix(m, pm.marker)
+	}
+	return strings.Contains(m, pm.marker)
+}
+
+var posMarkers = []posMarker{
+	{tag: "verb", marker: "to ", prefix: true},
+	{tag: "noun", marker: "CL:", prefix: true},
+	{tag: "classifier", marker: "classifier for ", prefix: true},
+	{tag: "surname", marker: "surname ", prefix: true},
+	{tag: "idiom", marker: "(idiom)"},
+	{tag: "colloquial", marker: "(coll.)"},
+	{tag: "literary", marker: "(literary)"},
+	{tag: "slang", marker: "(slang)"},
+	{tag: "dialect", marker: "(dialect)"},
+}
+
 var symbols = map[rune]string{
 	'？': "?",
 	'！': "!",
 	'：': ":",
 	'。': ".",
-	'・': ".",
+	'・': "·",
+	'･': "·",
+	'‧': "·",
 	'，': ",",
 	'；': ";",
 	'（': "(",
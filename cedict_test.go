@@ -5,18 +5,484 @@
 package cedict
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"testing/quick"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 var (
 	testDir = "./testdata"
 )
 
+// testEntries is a small offline sample of CC-CEDICT entries.
+var testEntries = []string{
+	"一 一 [yi1] /one/single/a (article)/",
+	"一行 一行 [yi1 xing2] /party/delegation/",
+	"中 中 [Zhong1] /China/Chinese/surname Zhong/",
+	"中 中 [zhong1] /within/among/in/middle/center/",
+	"中國 中国 [Zhong1 guo2] /China/",
+	"中國人 中国人 [Zhong1 guo2 ren2] /Chinese person/",
+	"中文 中文 [Zhong1 wen2] /Chinese language/",
+	"人 人 [ren2] /person/people/CL:個|个[ge4],位[wei4]/",
+	"人民 人民 [ren2 min2] /the people/CL:個|个[ge4]/",
+	"你 你 [ni3] /you (informal)/",
+	"你好 你好 [ni3 hao3] /hello/hi/",
+	"們 们 [men5] /plural marker for pronouns/",
+	"大 大 [da4] /big/large/great/",
+	"大學 大学 [da4 xue2] /university/college/CL:所[suo3]/",
+	"學 学 [xue2] /to learn/to study/",
+	"學校 学校 [xue2 xiao4] /school/CL:所[suo3]/",
+	"學生 学生 [xue2 sheng5] /student/schoolchild/",
+	"我 我 [wo3] /I/me/my/",
+	"我們 我们 [wo3 men5] /we/us/ourselves/our/",
+	"王 王 [wang2] /king or monarch/surname Wang/",
+	"的 的 [de5] /of/~'s (possessive particle)/",
+	"美國人 美国人 [Mei3 guo2 ren2] /American/American person/American people/CL:個|个[ge4]/",
+	"行 行 [hang2] /row/line/commercial firm/",
+	"行 行 [xing2] /to walk/to go/capable/",
+	"銀行 银行 [yin2 hang2] /bank/CL:家[jia1],個|个[ge4]/",
+}
+
+// parseTestDict returns a Dict parsed from CC-CEDICT formatted entries,
+// generating a minimal header with the matching entry count.
+func parseTestDict(tb testing.TB, entries ...string) *Dict {
+	tb.Helper()
+	s := fmt.Sprintf("#! entries=%d\n%s", len(entries), strings.Join(entries, "\n"))
+	d, err := ParseString(s)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return d
+}
+
+func TestReady(t *testing.T) {
+	parsed := parseTestDict(t, testEntries...)
+
+	// simulate the background load started by New
+	d := newDict()
+	d.mutex.Lock()
+	if d.Ready() {
+		t.Fatal("expected dict not to be ready before loading")
+	}
+	go func() {
+		defer d.mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		d.replace(parsed)
+	}()
+
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Ready() {
+		t.Error("expected dict to be ready after Err returns")
+	}
+}
+
+func TestDone(t *testing.T) {
+	parsed := parseTestDict(t, testEntries...)
+	select {
+	case <-parsed.Done():
+	default:
+		t.Error("expected parsed dict to be done")
+	}
+
+	// simulate the background load started by New
+	d := newDict()
+	d.mutex.Lock()
+	select {
+	case <-d.Done():
+		t.Fatal("expected dict not to be done before loading")
+	default:
+	}
+	go func() {
+		defer d.mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		d.replace(parsed)
+	}()
+
+	select {
+	case <-d.Done():
+		if !d.Ready() {
+			t.Error("expected dict to be ready when done")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for dict to load")
+	}
+}
+
+// failedTestDict returns a Dict as left by a failed download, without
+// any entries or indexes. Every call retries the failing download.
+func failedTestDict() *Dict {
+	d := newDict()
+	d.open = func() (io.ReadCloser, error) {
+		return nil, errors.New("download failed")
+	}
+	d.lazyLoad()
+	return d
+}
+
+func TestFailedLoad(t *testing.T) {
+	d := failedTestDict()
+	if d.Err() == nil {
+		t.Fatal("expected load error")
+	}
+	if d.Ready() {
+		t.Error("expected dict not to be ready after a failed download")
+	}
+	select {
+	case <-d.Done():
+	default:
+		t.Error("expected dict to be done after a failed download")
+	}
+
+	// text is returned unconverted
+	if got := d.HanziToPinyin("中文"); got != "中文" {
+		t.Errorf("got '%s' (want '中文')", got)
+	}
+	if got := d.HanziToPinyinWith("中文", ConvertOptions{Tones: true}); got != "中文" {
+		t.Errorf("got '%s' (want '中文')", got)
+	}
+	if got := d.Segment("中文"); !reflect.DeepEqual(got, []string{"中", "文"}) {
+		t.Errorf("got %q (want [中 文])", got)
+	}
+	if e := d.GetByHanzi("中文"); e != nil {
+		t.Errorf("got %v (want nil)", e)
+	}
+	e := &Entry{Traditional: "中文", Simplified: "中文", Pinyin: "Zhong1 wen2", Meanings: []string{"Chinese"}}
+	if before, after := d.Neighbors(e, 2); before != nil || after != nil {
+		t.Errorf("got %v, %v (want nil, nil)", before, after)
+	}
+
+	// script conversion leaves text unchanged
+	if got := d.ToSimplified("中國"); got != "中國" {
+		t.Errorf("got '%s' (want '中國')", got)
+	}
+	if got := d.ToTraditional("中国"); got != "中国" {
+		t.Errorf("got '%s' (want '中国')", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	extra := append(append([]string{}, testEntries...), "新 新 [xin1] /new/")
+	open := func(entries []string) func() (io.ReadCloser, error) {
+		return func() (io.ReadCloser, error) {
+			s := fmt.Sprintf("#! entries=%d\n%s", len(entries), strings.Join(entries, "\n"))
+			return ioutil.NopCloser(strings.NewReader(s)), nil
+		}
+	}
+
+	// every exported reader, called concurrently while reloading
+	readers := []func(){
+		func() { d.Err(); d.Ready(); d.DefaultFilename() },
+		func() { d.Filter(func(e *Entry) bool { return true }) },
+		func() { d.SingleCharEntries(); d.Idioms(); d.FourCharIdioms() },
+		func() { d.Stats(); d.Len(); d.EntryAt(0); d.Metadata(); d.Validate() },
+		func() { d.Search("中文"); d.Search("language"); d.Search("zhong1 wen2") },
+		func() { d.FuzzyHanzi("中文", 1); d.GetAllByHanzi("中文"); d.GroupedByHanzi("中文") },
+		func() { d.MeasureWordsFor("人"); d.PinyinOf("中文"); d.ReadingsOf("中") },
+		func() { d.Homophones("中", false); d.Homophones("中", true) },
+		func() { d.ContainingChar("中"); d.WordsWithPrefix("中"); d.WordsWithSuffix("文") },
+		func() { d.Neighbors(d.GetByHanzi("中文"), 2) },
+		func() { d.GetByPinyin("zhong1"); d.GetByPinyinGrouped("zhong"); d.GetByPinyinRanked("zhong") },
+		func() { d.SearchPinyinFuzzy("zhong wen") },
+		func() { d.GetByMeaning("language"); d.GetByMeaningMatches("language") },
+		func() { d.GetByMeaningPOS("language", "noun"); d.GetByMeaningStemmed("languages") },
+		func() { d.GetByMeaningWords([]string{"language"}, true); d.GetByMeaningQuery("language") },
+		func() {
+			d.HanziToPinyin("中文新")
+			d.HanziToPinyinSentences("中文。")
+			d.HanziToPinyinReport("中文")
+		},
+		func() { d.HanziToPinyinWith("中文", ConvertOptions{Tones: true}); d.HanziToPinyinPerChar("中文") },
+		func() { d.Segment("中文新"); d.NumberToPinyin(42); d.GetByRadical('文') },
+		func() { d.ConvertReader(strings.NewReader("中文"), ioutil.Discard) },
+		func() { d.WriteMarkdown(ioutil.Discard, d.GetAllByHanzi("中文")) },
+		func() { d.ToSimplified("中國"); d.ToTraditional("中国"); d.SimplifiedChars("中國") },
+		func() { d.HanziToPinyinVia("中國", Simplified) },
+	}
+
+	// read concurrently while reloading, each reader in its own goroutine
+	var wg sync.WaitGroup
+	for _, read := range readers {
+		wg.Add(1)
+		go func(read func()) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if e := d.GetByHanzi("中文"); e == nil {
+					t.Error("expected 中文 entry during reload")
+					return
+				}
+				read()
+			}
+		}(read)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for reading := true; reading; {
+		for _, entries := range [][]string{extra, testEntries} {
+			if err := d.reload(open(entries)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+	}
+
+	// last reload used the original entries
+	if e := d.GetByHanzi("新"); e != nil {
+		t.Errorf("got %s (want nil)", e.Marshal())
+	}
+	if err := d.reload(open(extra)); err != nil {
+		t.Fatal(err)
+	}
+	if e := d.GetByHanzi("新"); e == nil || d.Metadata().Entries != len(extra) {
+		t.Errorf("expected reloaded entries")
+	}
+
+	// failed reload keeps the current entries
+	err := d.reload(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("#! entries=5\n")), nil
+	})
+	if err == nil {
+		t.Error("expected parse error")
+	}
+	if e := d.GetByHanzi("新"); e == nil {
+		t.Error("expected entries to be kept after failed reload")
+	}
+}
+
+func TestDownloadError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := download(context.Background(), srv.URL)
+	if !errors.Is(err, ErrDownload) {
+		t.Fatalf("got %v (want ErrDownload)", err)
+	}
+	if errors.Is(err, ErrParse) {
+		t.Error("download error should not match ErrParse")
+	}
+	if !strings.Contains(err.Error(), "404") {
+		t.Errorf("got '%v' (want bad status)", err)
+	}
+
+	// corrupt gzip content
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "\x1f\x8bnot gzip")
+	}))
+	defer srv.Close()
+	if _, err := download(context.Background(), srv.URL); !errors.Is(err, ErrDownload) {
+		t.Errorf("got %v (want ErrDownload)", err)
+	}
+}
+
+func TestDownloadPlainText(t *testing.T) {
+	s := fmt.Sprintf("#! entries=%d\n%s", len(testEntries), strings.Join(testEntries, "\n"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, s)
+	}))
+	defer srv.Close()
+
+	for _, url := range []string{srv.URL + "/cedict.txt", srv.URL + "/cedict.txt.gz"} {
+		r, err := Download(WithURL(url))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := Parse(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+		if len(d.e) != len(testEntries) {
+			t.Errorf("%s: got %d entries (want %d)", url, len(d.e), len(testEntries))
+		}
+	}
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	s := fmt.Sprintf("#! entries=%d\n%s", len(testEntries), strings.Join(testEntries, "\n"))
+	b := gzipBytes(t, []byte(s))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	// matching checksum, case insensitive
+	sum := sha256.Sum256(b)
+	want := strings.ToUpper(hex.EncodeToString(sum[:]))
+	r, err := download(context.Background(), srv.URL, WithSHA256(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	d, err := Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.e) != len(testEntries) {
+		t.Errorf("got %d entries (want %d)", len(d.e), len(testEntries))
+	}
+
+	// mismatching checksum
+	bad := strings.Repeat("0", 64)
+	_, err = download(context.Background(), srv.URL, WithSHA256(bad))
+	if !errors.Is(err, ErrDownload) || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("got %v (want checksum mismatch)", err)
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	header := "# CC-CEDICT\n" +
+		"#! version=1\n" +
+		"#! subversion=0\n" +
+		"#! format=ts\n" +
+		"#! charset=UTF-8\n" +
+		"#! entries=2\n" +
+		"#! publisher=MDBG\n" +
+		"#! date=2020-05-01T12:34:56Z\n"
+	b := gzipBytes(t, []byte(header+strings.Join(testEntries[:2], "\n")))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	md, err := latestVersion(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2020, 5, 1, 12, 34, 56, 0, time.UTC)
+	if md.Version != 1 || md.Subversion != 0 || !md.Timestamp.Equal(date) {
+		t.Errorf("got %+v", md)
+	}
+	if md.Entries != 2 || md.Publisher != "MDBG" {
+		t.Errorf("got %+v", md)
+	}
+
+	// invalid header
+	b = gzipBytes(t, []byte("#! date=yesterday\n"))
+	if _, err := latestVersion(context.Background(), srv.URL); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v (want ErrParse)", err)
+	}
+
+	// cancelled request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := latestVersion(ctx, srv.URL); !errors.Is(err, ErrDownload) {
+		t.Errorf("got %v (want ErrDownload)", err)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	header := []string{
+		"# CC-CEDICT",
+		"# Community maintained free Chinese-English dictionary.",
+		"#! version=1",
+		"#! subversion=0",
+		"#! format=ts",
+		"#! charset=UTF-8",
+		"#! entries=2",
+		"#! publisher=MDBG",
+		"#! license=https://creativecommons.org/licenses/by-sa/4.0/",
+		"#! date=2020-05-01T12:34:56Z",
+	}
+	s := strings.Join(append(header, testEntries[:2]...), "\n")
+
+	md, lines, err := ParseHeader(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lines, header) {
+		t.Errorf("got header %q", lines)
+	}
+
+	// metadata matches a full parse
+	d, err := ParseString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md != d.Metadata() {
+		t.Errorf("got %+v (want %+v)", md, d.Metadata())
+	}
+	if md.Charset != "UTF-8" || md.Entries != 2 || md.License == "" {
+		t.Errorf("got %+v", md)
+	}
+
+	// entries after the header are not parsed
+	if _, _, err := ParseHeader(strings.NewReader("#! entries=1\nbad entry")); err != nil {
+		t.Errorf("got %v (want nil)", err)
+	}
+	if _, _, err := ParseHeader(strings.NewReader("#! version=x\n")); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v (want ErrParse)", err)
+	}
+}
+
+func TestParseBlankLines(t *testing.T) {
+	s := "#! version=1\n\n#! entries=3\n\n" +
+		"中 中 [zhong1] /within/among/\r\n" +
+		"\r\n" +
+		"中國 中国 [Zhong1 guo2] /China/\n" +
+		"   \n\t\n" +
+		"人 人 [ren2] /person/\n" +
+		"\n\n"
+
+	d, err := ParseString(s)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(d.e) != 3 || d.Metadata().Entries != 3 {
+		t.Errorf("got %d entries (want 3)", len(d.e))
+	}
+	if d.GetByHanzi("人") == nil {
+		t.Error("expected entry after blank lines")
+	}
+
+	md, _, err := ParseHeader(strings.NewReader(s))
+	if err != nil || md.Version != 1 || md.Entries != 3 {
+		t.Errorf("got %+v, %v", md, err)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := ParseString("#! entries=1\nbad entry")
+	if !errors.Is(err, ErrParse) {
+		t.Fatalf("got %v (want ErrParse)", err)
+	}
+	if errors.Is(err, ErrDownload) {
+		t.Error("parse error should not match ErrDownload")
+	}
+
+	// wrapped errors still match
+	if !errors.Is(errors.WithStack(err), ErrParse) {
+		t.Error("expected wrapped error to match ErrParse")
+	}
+}
+
 func TestLoadSave(t *testing.T) {
 
 	// cleanup test data
@@ -127,14 +593,20 @@ func TestPinyin(t *testing.T) {
 		"Ni3 hao2 ma5":   "Nǐ háo ma",
 		"Mei3 guo2 ren2": "Měi guó rén",
 		"Me3i guo2 re2n": "Měi guó rén",
+		"Bei3jing1":      "Běijīng",
+		"Xi1'an1":        "Xī'ān",
+		"Mao2 Ze2dong1":  "Máo Zédōng",
 	}
 
 	toneToNum := map[string]string{
 		"üz zǖz zü":   "u:z zu:z1 zu:",
 		"Zhōng wén":   "Zhong1 wen2",
 		"zhōng Wén":   "zhong1 Wen2",
-		"Nǐ háo ma":   "Ni3 hao2 ma", // 5?
+		"Nǐ háo ma":   "Ni3 hao2 ma", // neutral tone is unmarked
 		"Měi guó rén": "Mei3 guo2 ren2",
+		"Běijīng":     "Bei3jing1",
+		"Xī'ān":       "Xi1'an1",
+		"Máo Zédōng":  "Mao2 Ze2dong1",
 	}
 
 	for withNum, withTones := range numToTone {
@@ -151,136 +623,1203 @@ func TestPinyin(t *testing.T) {
 	}
 }
 
-func TestMeaning(t *testing.T) {
-	d := New()
-	elements := d.GetByMeaning("Chinese Language")
-	for _, e := range elements[:1] {
-		t.Logf("ByMeaning: %s\n", e.Marshal())
-	}
-	if len(elements) < 1 || elements[0].Pinyin != "Zhong1 wen2" {
-		t.Fail()
-	}
-}
+func TestUmlautFallbacks(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"綠 绿 [lu:4] /green/",
+		"女 女 [nu:3] /female/woman/",
+		"路 路 [lu4] /road/",
+	)...)
 
-func TestMetadata(t *testing.T) {
-	s := `# CC-CEDICT
-# Community maintained free Chinese-English dictionary.
-#
-# Published by MDBG
-#
-# License:
-# Creative Commons Attribution-ShareAlike 4.0 International License
-# https://creativecommons.org/licenses/by-sa/4.0/
-#
-# Referenced works:
-# CEDICT - Copyright (C) 1997, 1998 Paul Andrew Denisowski
-#
-# CC-CEDICT can be downloaded from:
-# https://www.mdbg.net/chinese/dictionary?page=cc-cedict
-#
-# Additions and corrections can be sent through:
-# https://cc-cedict.org/editor/editor.php
-#
-# For more information about CC-CEDICT see:
-# https://cc-cedict.org/wiki/
-#
-#! version=123
-#! subversion=456
-#! format=ts
-#! charset=UTF-8
-#! entries=4
-#! publisher=MDBG
-#! license=https://creativecommons.org/licenses/by-sa/4.0/
-#! date=2020-02-14T06:15:46Z
-#! time=1581660946
-% % [pa1] /percent (Tw)/
-21三體綜合症 21三体综合症 [er4 shi2 yi1 san1 ti3 zong1 he2 zheng4] /trisomy/Down's syndrome/
-3C 3C [san1 C] /abbr. for computers, communications, and consumer electronics/China Compulsory Certificate (CCC)/
-3P 3P [san1 P] /(slang) threesome/`
-	r := strings.NewReader(s)
-	d, err := Parse(r)
-	if err != nil {
-		t.Fatal(err)
+	// u:, ü and v are always supported
+	for _, in := range []string{"lu:4", "lü4", "lǜ", "lv4", "LV4", "lv"} {
+		if got := d.GetByPinyin(in); len(got) != 1 || got[0].Simplified != "绿" {
+			t.Errorf("'%s' - got %v (want 绿)", in, got)
+		}
 	}
-	md := d.Metadata()
-	if md.Version != 123 {
-		t.Errorf("version != 123")
+	tones := map[string]string{
+		"lv4":        "lǜ",
+		"nv3 ren2":   "nǚ rén",
+		"lve4":       "lüè",
+		"V C D":      "V C D",
+		"lu4 luu4":   "lù lùu",
+		"Lu:4 LU:4 ": "Lǜ LǛ",
 	}
-	if md.Subversion != 456 {
-		t.Errorf("subversion != 456")
+	for in, want := range tones {
+		if got := PinyinTones(in); got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
 	}
-	if md.Format != "ts" {
-		t.Errorf("format != ts")
+	if got, want := PinyinToneNums("nǚ lv"), "nu:3 lu:"; got != want {
+		t.Errorf("got '%s' (want '%s')", got, want)
 	}
-	if md.Charset != "UTF-8" {
-		t.Errorf("charset != UTF-8")
+
+	// fallbacks aren't converted in words other than pinyin syllables
+	mixed := []struct{ got, want string }{
+		{PinyinTones(d.HanziToPinyin("綠 silver")), "Lǜ silver"},
+		{PinyinTones("involve conversation nv3"), "involve conversation nǚ"},
+		{FormatPinyin("ni3 hao3 silver", ToneMarks), "nǐ hǎo silver"},
+		{PinyinToneNums("Lv Nvren silver"), "Lu: Nvren silver"},
 	}
-	if md.Entries != 4 {
-		t.Errorf("entries != 4")
+	for _, test := range mixed {
+		if test.got != test.want {
+			t.Errorf("got '%s' (want '%s')", test.got, test.want)
+		}
 	}
-	if md.Publisher != "MDBG" {
-		t.Errorf("publisher != MDBG")
+
+	// uu is only supported when enabled
+	if got := d.GetByPinyin("luu4"); len(got) != 0 {
+		t.Errorf("got %v (want none)", got)
 	}
-	if md.License != "https://creativecommons.org/licenses/by-sa/4.0/" {
-		t.Errorf("license != https://creativecommons.org/licenses/by-sa/4.0/")
+	DoubleUFallback = true
+	defer func() { DoubleUFallback = false }()
+	if got := d.GetByPinyin("luu4"); len(got) != 1 || got[0].Simplified != "绿" {
+		t.Errorf("got %v (want 绿)", got)
 	}
-	if md.Timestamp.Format(time.RFC3339) != "2020-02-14T06:15:46Z" {
-		t.Errorf("date != 2020-02-14T06:15:46Z")
+	if got := d.GetByPinyin("nuu3"); len(got) != 1 || got[0].Simplified != "女" {
+		t.Errorf("got %v (want 女)", got)
 	}
-	if md.Timestamp.Unix() != 1581660946 {
-		t.Errorf("time != 1581660946")
+	if got, want := PinyinTones("luu4 nuu3 lu4"), "lǜ nǚ lù"; got != want {
+		t.Errorf("got '%s' (want '%s')", got, want)
 	}
 }
 
-func TestLoadInvalid(t *testing.T) {
+func TestPinyinTonesUppercase(t *testing.T) {
 	tests := map[string]string{
-		"#! version=\n":                 "expected number",
-		"#! subversion=abc\n":           "expected number",
-		"#! entries=a1 \n":              "expected number",
-		"#! date=2020-0214T06:15:46Z\n": "expected RFC3339",
-		"#! entries=1\n":                "loaded entries (0)",
-		"% % [pa1 /percent (Tw)/\n":     "unmarshal: ",
+		"YI1":        "YĪ",
+		"WU4":        "WÙ",
+		"Yi1 Yu3":    "Yī Yǔ",
+		"NU:3":       "NǙ",
+		"LU:E4":      "LÜÈ",
+		"U:":         "Ü",
+		"XI2 AN1":    "XÍ ĀN",
+		"BEI3 JING1": "BĚI JĪNG",
+		"ZHONG1 OU1": "ZHŌNG ŌU",
+		"Ai4 Er3":    "Ài Ěr",
+		"CHUI1":      "CHUĪ",
+		"GUI4":       "GUÌ",
+		"SHU1":       "SHŪ",
+		"QU4":        "QÙ",
 	}
-	for s, wantErr := range tests {
-		r := strings.NewReader(s)
-		d, err := Parse(r)
-		if err == nil || !strings.Contains(err.Error(), wantErr) {
-			var md Metadata
-			if d != nil {
-				md = d.Metadata()
-			}
-			t.Errorf("got '%v', want '%s'\n%v", err, wantErr, md)
+	for withNum, withTones := range tests {
+		if got := PinyinTones(withNum); got != withTones {
+			t.Errorf("PinyinTones('%s') got '%s' (want '%s')", withNum, got, withTones)
+		}
+		if got := PinyinToneNums(withTones); got != withNum {
+			t.Errorf("PinyinToneNums('%s') got '%s' (want '%s')", withTones, got, withNum)
 		}
 	}
 }
 
-func TestEntry(t *testing.T) {
+// tonePinyin is random pinyin in the canonical CC-CEDICT form,
+// generated for property tests of the tone conversions.
+type tonePinyin string
 
-	equal := func(s string, e *Entry) error {
-		o := &Entry{}
-		if err := o.Unmarshal(s); err != nil {
-			return err
+// Generate returns space separated syllables, with random tones and
+// case, and erhua added after some syllables with tones 1-4.
+func (tonePinyin) Generate(rand *rand.Rand, size int) reflect.Value {
+	var syllables []string
+	for s := range validSyllables {
+		if s != "r" {
+			syllables = append(syllables, s)
 		}
-		if o.Traditional != e.Traditional {
-			return fmt.Errorf("'%s' != '%s'", o.Traditional, e.Traditional)
+	}
+	sort.Strings(syllables)
+
+	var words []string
+	for i := rand.Intn(6); i >= 0; i-- {
+		p := syllables[rand.Intn(len(syllables))]
+		tone := 1 + rand.Intn(5)
+		switch rand.Intn(3) {
+		case 1:
+			p = strings.ToUpper(p[:1]) + p[1:]
+		case 2:
+			p = strings.ToUpper(p)
 		}
-		if o.Simplified != e.Simplified {
-			return fmt.Errorf("'%s' != '%s'", o.Simplified, e.Simplified)
+		erhua := tone < 5 && guessToneIndex(p) >= 0 && !validSyllables[strings.ToLower(p)+"r"]
+		p = strings.NewReplacer("v", "u:", "V", "U:").Replace(p)
+		words = append(words, fmt.Sprintf("%s%d", p, tone))
+		if erhua && rand.Intn(8) == 0 {
+			words = append(words, "r5")
 		}
-		if o.Pinyin != e.Pinyin {
-			return fmt.Errorf("'%s' != '%s'", o.Pinyin, e.Pinyin)
+	}
+	return reflect.ValueOf(tonePinyin(strings.Join(words, " ")))
+}
+
+// canonical returns the pinyin as written by PinyinToneNums,
+// without the neutral tone number for syllables with vowels,
+// other than erhua.
+func (p tonePinyin) canonical() string {
+	words := strings.Fields(string(p))
+	for i, w := range words {
+		if w != "r5" && strings.HasSuffix(w, "5") && guessToneIndex(w) >= 0 {
+			words[i] = strings.TrimSuffix(w, "5")
 		}
-		for i := range o.Meanings {
-			if o.Meanings[i] != e.Meanings[i] {
-				return fmt.Errorf("[%d] '%s' != '%s'", i, o.Meanings[i], e.Meanings[i])
-			}
+	}
+	return strings.Join(words, " ")
+}
+
+func TestPinyinRoundTrip(t *testing.T) {
+	f := func(p tonePinyin) bool {
+		tones := PinyinTones(string(p))
+		if got := PinyinToneNums(tones); got != p.canonical() {
+			t.Logf("'%s' -> '%s' -> '%s' (want '%s')", p, tones, got, p.canonical())
+			return false
 		}
-		return nil
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
 	}
 
-	if err := equal("中 中 [Zhong1] /China/Chinese/surname Zhong/", &Entry{
-		Traditional: "中",
-		Simplified:  "中",
+	// conversion to tone marks is stable for canonical tone marks
+	g := func(p tonePinyin) bool {
+		tones := PinyinTones(string(p))
+		return PinyinTones(PinyinToneNums(tones)) == tones
+	}
+	if err := quick.Check(g, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPinyinOf(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	tests := map[string]string{
+		"中文":  "Zhong1 wen2",
+		"中國人": "Zhong1 guo2 ren2",
+		"中国人": "Zhong1 guo2 ren2",
+		"中文人": "",
+	}
+	for in, want := range tests {
+		got, ok := d.PinyinOf(in)
+		if got != want || ok != (want != "") {
+			t.Errorf("'%s' - got '%s', %v (want '%s')", in, got, ok, want)
+		}
+	}
+}
+
+func TestHanziToPinyinReport(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	// 龘 and 犇 are not in the test dict
+	p, unknown := d.HanziToPinyinReport("我龘中文犇龘")
+	if p != d.HanziToPinyin("我龘中文犇龘") {
+		t.Errorf("got '%s', different to HanziToPinyin", p)
+	}
+	if string(unknown) != "龘犇" {
+		t.Errorf("got %q (want 龘犇)", string(unknown))
+	}
+
+	p, unknown = d.HanziToPinyinReport("我們 abc")
+	if p != "Wo3 men5 abc" || unknown != nil {
+		t.Errorf("got '%s', %q (want 'Wo3 men5 abc', none)", p, string(unknown))
+	}
+}
+
+func TestHanziToPinyinPerChar(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"銀 银 [yin2] /silver/",
+	)...)
+
+	tests := []struct {
+		in, word, char string
+	}{
+		{"银行", "Yin2 hang2", "Yin2 xing2"},
+		{"我们的大学", "Wo3 men5 de5 da4 xue2", "Wo3 men5 de5 da4 xue2"},
+		{"我的银行", "Wo3 de5 yin2 hang2", "Wo3 de5 yin2 xing2"},
+
+		// characters only found in words are unknown
+		{"中文", "Zhong1wen2", "Zhong1 文"},
+	}
+	for _, tt := range tests {
+		if got := d.HanziToPinyin(tt.in); got != tt.word {
+			t.Errorf("'%s' - got '%s' (want '%s')", tt.in, got, tt.word)
+		}
+		if got := d.HanziToPinyinPerChar(tt.in); got != tt.char {
+			t.Errorf("'%s' - got '%s' per char (want '%s')", tt.in, got, tt.char)
+		}
+	}
+}
+
+func TestHanziToPinyinWith(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"西安 西安 [Xi1 an1] /Xi'an/",
+	)...)
+
+	// syllables of a word are joined
+	joined := ConvertOptions{Tones: true, JoinSyllables: true}
+	if got := strings.ToLower(d.HanziToPinyinWith("中文", joined)); got != "zhōngwén" {
+		t.Errorf("got '%s' (want 'zhōngwén')", got)
+	}
+
+	tests := []struct {
+		in   string
+		opts ConvertOptions
+		want string
+	}{
+		{"我们的大学", ConvertOptions{}, "Wo3 men5 de5 da4 xue2"},
+		{"我们的大学", ConvertOptions{Tones: true}, "Wǒ men de dà xué"},
+		{"我们的大学", ConvertOptions{JoinSyllables: true}, "Wo3men5 de5 da4xue2"},
+		{"我们的大学", joined, "Wǒmen de dàxué"},
+		{"你好中国人", joined, "Nǐhǎo Zhōngguórén"},
+		{"西安", joined, "Xī'ān"},
+		{"我们的大学", ConvertOptions{PerChar: true, JoinSyllables: true}, "Wo3 men5 de5 da4 xue2"},
+	}
+	for _, tt := range tests {
+		if got := d.HanziToPinyinWith(tt.in, tt.opts); got != tt.want {
+			t.Errorf("'%s' %+v - got '%s' (want '%s')", tt.in, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownCharMode(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	// 猫 isn't in the dict
+	tests := []struct {
+		in   string
+		mode UnknownCharMode
+		want string
+	}{
+		{"我猫你", UnknownCharKeep, "Wo3 猫ni3"},
+		{"我猫你", ConvertOptions{}.Unknown, "Wo3 猫ni3"},
+		{"我猫你", UnknownCharDrop, "Wo3 ni3"},
+		{"我猫你", UnknownCharReplace("?"), "Wo3 ? ni3"},
+		{"我猫猫你", UnknownCharReplace("[?]"), "Wo3 [?] [?] ni3"},
+		{"猫你", UnknownCharDrop, "Ni3"},
+		{"猫", UnknownCharDrop, ""},
+	}
+	for _, tt := range tests {
+		got := d.HanziToPinyinWith(tt.in, ConvertOptions{Unknown: tt.mode})
+		if got != tt.want {
+			t.Errorf("'%s' %+v - got '%s' (want '%s')", tt.in, tt.mode, got, tt.want)
+		}
+	}
+
+	// tone marks are also supported
+	opts := ConvertOptions{Tones: true, Unknown: UnknownCharReplace("?")}
+	if got, want := d.HanziToPinyinWith("我猫你", opts), "Wǒ ? nǐ"; got != want {
+		t.Errorf("got '%s' (want '%s')", got, want)
+	}
+}
+
+func TestHanziToPinyinMiddleDot(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"比爾・蓋茨 比尔・盖茨 [Bi3 er3 · Gai4 ci2] /Bill Gates/",
+		"阿爾伯特·愛因斯坦 阿尔伯特·爱因斯坦 [A1 er3 bo2 te4 · Ai4 yin1 si1 tan3] /Albert Einstein/",
+		"阿爾伯特 阿尔伯特 [A1 er3 bo2 te4] /Albert/",
+		"愛因斯坦 爱因斯坦 [Ai4 yin1 si1 tan3] /Einstein/",
+	)...)
+
+	// any middle dot matches names written with another
+	tests := map[string]string{
+		"比尔・盖茨":      "Bi3'er3 · Gai4ci2",
+		"比尔·盖茨":      "Bi3'er3 · Gai4ci2",
+		"比爾‧蓋茨":      "Bi3'er3 · Gai4ci2",
+		"我的比尔・盖茨":    "Wo3 de5 Bi3'er3 · Gai4ci2",
+		"阿尔伯特・爱因斯坦":  "A1'er3bo2te4 · Ai4yin1si1tan3",
+		"阿尔伯特·爱因斯坦":  "A1'er3bo2te4 · Ai4yin1si1tan3",
+		"阿尔伯特・爱因斯坦。": "A1'er3bo2te4 · Ai4yin1si1tan3 .",
+	}
+	for in, want := range tests {
+		if got := d.HanziToPinyin(in); got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
+	}
+
+	// names aren't split into sentences
+	if got, want := d.HanziToPinyinSentences("我的比尔・盖茨"), "Wo3 de5 Bi3'er3 · Gai4ci2"; got != want {
+		t.Errorf("got '%s' (want '%s')", got, want)
+	}
+	if got, want := PinyinTones(d.HanziToPinyin("比尔・盖茨")), "Bǐ'ěr · Gàicí"; got != want {
+		t.Errorf("got '%s' (want '%s')", got, want)
+	}
+
+	// lookups also match either form
+	if e := d.GetByHanzi("比尔·盖茨"); e == nil || e.Simplified != "比尔・盖茨" {
+		t.Errorf("got %v (want 比尔・盖茨)", e)
+	}
+}
+
+func TestHanziToPinyinSentences(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	tests := map[string]string{
+		"":           "",
+		"你好。我們的大學！":  "Ni3 hao3 . Wo3 men5 de5 da4 xue2 !",
+		"我們？中國人！你好":  "Wo3 men5 ? Zhong1guo2ren2 ! Ni3 hao3",
+		"你好！ 3.5人。的": "Ni3 hao3 ! 3.5 ren2 . De5",
+		"你好. \"我們\"": "Ni3 hao3 . \" Wo3 men5 \"",
+		"我們的大學，你好。":  "Wo3 men5 de5 da4 xue2 , ni3 hao3 .",
+	}
+	for in, want := range tests {
+		if got := d.HanziToPinyinSentences(in); got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
+	}
+}
+
+func TestPinyinTonesErhua(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"哪兒 哪儿 [na3 r5] /where?/",
+		"一點兒 一点儿 [yi1 dian3 r5] /a little/",
+	)...)
+
+	tests := map[string]string{
+		"哪兒":  "Nǎr",
+		"一點兒": "Yī diǎnr",
+		"一点儿": "Yī diǎnr",
+	}
+	for in, want := range tests {
+		got := PinyinTones(d.HanziToPinyin(in))
+		if got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
+	}
+
+	// erhua is merged in plain pinyin strings too
+	if got := PinyinTones("wan2 r5 yi1 hui4 r5"); got != "wánr yī huìr" {
+		t.Errorf("got '%s' (want 'wánr yī huìr')", got)
+	}
+
+	// and split again when converting to tone numbers
+	for withTones, withNum := range map[string]string{
+		"wánr yī huìr": "wan2 r5 yi1 hui4 r5",
+		"Nǎr":          "Na3 r5",
+		"ér zi":        "er2 zi",
+		"lǜr":          "lu:4 r5",
+	} {
+		if got := PinyinToneNums(withTones); got != withNum {
+			t.Errorf("'%s' - got '%s' (want '%s')", withTones, got, withNum)
+		}
+	}
+}
+
+func TestHanziToPinyinProperNouns(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"北京 北京 [Bei3 jing1] /Beijing/",
+		"西安 西安 [Xi1 an1] /Xi'an/",
+		"毛澤東 毛泽东 [Mao2 Ze2 dong1] /Mao Zedong/",
+		"去 去 [qu4] /to go/",
+	)...)
+
+	tests := map[string]string{
+		"北京":    "Běijīng",
+		"我去北京":  "Wǒ qù Běijīng",
+		"西安":    "Xī'ān",
+		"毛泽东":   "Máo Zédōng",
+		"我們去中國": "Wǒ men qù Zhōngguó",
+		"中":     "Zhōng",
+		"我中":    "Wǒ zhōng",
+		"ABC北京": "Abc Běijīng",
+	}
+	for in, want := range tests {
+		got := PinyinTones(d.HanziToPinyin(in))
+		if got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
+	}
+}
+
+func TestIsProperNoun(t *testing.T) {
+	tests := map[string]bool{
+		"北京 北京 [Bei3 jing1] /Beijing/": true,
+		"王 王 [Wang2] /surname Wang/":   true,
+		"王 王 [wang2] /king/":           false,
+		"去 去 [qu4] /to go/":            false,
+	}
+	for line, want := range tests {
+		e := &Entry{}
+		if err := e.Unmarshal(line); err != nil {
+			t.Fatal(err)
+		}
+		if got := e.IsProperNoun(); got != want {
+			t.Errorf("'%s' - got %v (want %v)", line, got, want)
+		}
+	}
+}
+
+func TestPartOfSpeech(t *testing.T) {
+	tests := map[string][]string{
+		"跑 跑 [pao3] /to run/to run away/":                                           {"verb"},
+		"畫蛇添足 画蛇添足 [hua4 she2 tian1 zu2] /lit. draw legs on a snake (idiom)/":       {"idiom"},
+		"一石二鳥 一石二鸟 [yi1 shi2 er4 niao3] /to kill two birds with one stone (idiom)/": {"verb", "idiom"},
+		"條 条 [tiao2] /strip/classifier for long thin things/":                       {"classifier"},
+		"人 人 [ren2] /person/people/CL:個|个[ge4],位[wei4]/":                            {"noun"},
+		"王 王 [Wang2] /surname Wang/":                                                {"surname"},
+		"搞 搞 [gao3] /to do/to make (coll.)/":                                        {"verb", "colloquial"},
+		"你好 你好 [ni3 hao3] /hello/hi/":                                               nil,
+	}
+	for line, want := range tests {
+		e := &Entry{}
+		if err := e.Unmarshal(line); err != nil {
+			t.Fatal(err)
+		}
+		if got := e.PartOfSpeech(); !reflect.DeepEqual(got, want) {
+			t.Errorf("'%s' - got %v (want %v)", line, got, want)
+		}
+	}
+}
+
+func TestGetByMeaningPOS(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"跑 跑 [pao3] /to run/to run away/",
+		"跑步 跑步 [pao3 bu4] /run/",
+		"一石二鳥 一石二鸟 [yi1 shi2 er4 niao3] /to kill two birds with one stone (idiom)/",
+	)...)
+
+	verbs := d.GetByMeaningPOS("to run", "verb")
+	if len(verbs) != 1 || verbs[0].Simplified != "跑" {
+		t.Errorf("got %v (want 跑)", verbs)
+	}
+	idioms := d.GetByMeaningPOS("to kill two birds with one stone (idiom)", "idiom")
+	if len(idioms) != 1 || idioms[0].Simplified != "一石二鸟" {
+		t.Errorf("got %v (want 一石二鸟)", idioms)
+	}
+	if got := d.GetByMeaningPOS("to run", "idiom"); len(got) != 0 {
+		t.Errorf("got %v (want none)", got)
+	}
+}
+
+func TestGetByHanziPercent(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"% % [pa1] /percent (Tw)/",
+	)...)
+
+	e := d.GetByHanzi("%")
+	if e == nil {
+		t.Fatal("expected '%' entry")
+	}
+	if e.Pinyin != "pa1" || len(e.Meanings) != 1 || e.Meanings[0] != "percent (Tw)" {
+		t.Errorf("got %s", e.Marshal())
+	}
+
+	// non-hanzi input is left unconverted
+	if got := d.HanziToPinyin("中%"); got != "Zhong1 %" {
+		t.Errorf("got '%s' (want 'Zhong1 %%')", got)
+	}
+}
+
+func TestMeasureWordsFor(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"個 个 [ge4] /individual/classifier for people or objects in general/",
+		"位 位 [wei4] /position/classifier for people (courteous)/",
+		"書 书 [shu1] /book/CL:本[ben3],冊|册[ce4],部[bu4]/",
+		"本 本 [ben3] /root/classifier for books/",
+	)...)
+
+	tests := map[string][]string{
+		"人": {"个", "位"},
+		"書": {"本"},
+		"书": {"本"},
+		"你": nil,
+		"國": nil,
+	}
+	for in, want := range tests {
+		var got []string
+		for _, e := range d.MeasureWordsFor(in) {
+			got = append(got, e.Simplified)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("'%s' - got %v (want %v)", in, got, want)
+		}
+	}
+}
+
+func TestFuzzyHanzi(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	// 囯 is a mistyped 国
+	results := d.FuzzyHanzi("中囯", 1)
+	if len(results) == 0 || results[0].Simplified != "中国" {
+		t.Fatalf("got %v (want 中国 first)", results)
+	}
+	for _, e := range results {
+		if levenshtein("中囯", e.Simplified) > 1 && levenshtein("中囯", e.Traditional) > 1 {
+			t.Errorf("got %s, too far from input", e.Simplified)
+		}
+	}
+
+	// exact matches are sorted first
+	results = d.FuzzyHanzi("中國", 1)
+	if len(results) == 0 || results[0].Traditional != "中國" {
+		t.Errorf("got %v (want 中國 first)", results)
+	}
+
+	if results := d.FuzzyHanzi("中囯", 0); len(results) != 0 {
+		t.Errorf("got %v (want none)", results)
+	}
+	if results := d.FuzzyHanzi("", 2); results != nil {
+		t.Errorf("got %v (want nil)", results)
+	}
+}
+
+func TestGetAllByHanzi(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	tests := map[string]int{
+		"中":  2,
+		"中國": 1,
+		"中国": 1,
+		"國":  0,
+	}
+	for in, want := range tests {
+		if got := d.GetAllByHanzi(in); len(got) != want {
+			t.Errorf("'%s' - got %d entries (want %d)", in, len(got), want)
+		}
+	}
+}
+
+func TestGetByHanziLatin(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"卡拉OK 卡拉OK [ka3 la1 O K] /karaoke/",
+		"3C 3C [san1 C] /computers, communications, and consumer electronics/",
+		"T恤 T恤 [T xu4] /T-shirt/",
+		"t恤 t恤 [t xu4] /not a real word/",
+	)...)
+
+	tests := map[string]string{
+		"卡拉OK":  "卡拉OK",
+		"卡拉ok":  "卡拉OK",
+		" 卡拉Ok": "卡拉OK",
+		"3c":    "3C",
+		"3C":    "3C",
+
+		// exact matches are preferred
+		"T恤": "T恤",
+		"t恤": "t恤",
+	}
+	for in, want := range tests {
+		e := d.GetByHanzi(in)
+		if e == nil || e.Simplified != want {
+			t.Errorf("'%s' - got %v (want %s)", in, e, want)
+		}
+	}
+	if got := d.GetAllByHanzi("卡拉ok"); len(got) != 1 {
+		t.Errorf("got %v (want 1 entry)", got)
+	}
+	if e := d.GetByHanzi("卡拉o"); e != nil {
+		t.Errorf("got %v (want nil)", e)
+	}
+
+	// script conversion keeps the case of the text
+	for _, in := range []string{"卡拉ok", "卡拉OK", "我的卡拉Ok"} {
+		if got := d.ToSimplified(in); got != in {
+			t.Errorf("ToSimplified - got '%s' (want '%s')", got, in)
+		}
+		if got := d.ToTraditional(in); got != in {
+			t.Errorf("ToTraditional - got '%s' (want '%s')", got, in)
+		}
+	}
+
+	// lowercase input is also searched
+	if got := d.Search("卡拉ok"); len(got) != 1 || got[0].Simplified != "卡拉OK" {
+		t.Errorf("got %v (want 卡拉OK)", got)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	tests := []struct {
+		query string
+		want  []*Entry
+	}{
+		{"中", d.GetAllByHanzi("中")},
+		{" 中国 ", d.GetAllByHanzi("中國")},
+		{"zhong1 guo2", d.GetByPinyin("zhong1 guo2")},
+		{"Zhong1 wen2", d.GetByPinyin("zhong1 wen2")},
+		{"people", d.GetByMeaning("people")},
+		{"bank", d.GetByMeaning("bank")},
+		{"國", nil},
+	}
+	for _, tt := range tests {
+		got := d.Search(tt.query)
+		if len(got) == 0 && tt.want != nil {
+			t.Errorf("'%s' - got no entries", tt.query)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("'%s' - got %v (want %v)", tt.query, got, tt.want)
+		}
+	}
+
+	// valid pinyin without pinyin matches is searched by meaning
+	if got := d.Search("he"); !reflect.DeepEqual(got, d.GetByMeaning("he")) {
+		t.Errorf("'he' - got %v (want meaning matches)", got)
+	}
+}
+
+func TestGroupedByHanzi(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"行 行 [xing2] /competent/",
+	)...)
+
+	g := d.GroupedByHanzi("行")
+	want := &GroupedEntry{
+		Hanzi: "行",
+		Readings: []Reading{
+			{Pinyin: "hang2", Meanings: []string{"row", "line", "commercial firm"}},
+			{Pinyin: "xing2", Meanings: []string{"to walk", "to go", "capable", "competent"}},
+		},
+	}
+	if !reflect.DeepEqual(g, want) {
+		t.Errorf("got %+v (want %+v)", g, want)
+	}
+	if g := d.GroupedByHanzi("國"); g != nil {
+		t.Errorf("got %+v (want nil)", g)
+	}
+}
+
+func TestGetByPinyinGrouped(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"媽 妈 [ma1] /mum/",
+		"麻 麻 [ma2] /hemp/",
+		"馬 马 [Ma3] /surname Ma/",
+		"馬 马 [ma3] /horse/",
+		"碼 码 [ma3] /weight/number/",
+		"罵 骂 [ma4] /to scold/",
+		"嗎 吗 [ma5] /question particle/",
+		"媽媽 妈妈 [ma1 ma5] /mum/",
+	)...)
+
+	want := map[string][]string{
+		"ma1": {"妈"},
+		"ma2": {"麻"},
+		"ma3": {"马", "马", "码"},
+		"ma4": {"骂"},
+		"ma5": {"吗"},
+	}
+	for _, in := range []string{"ma", "ma3", "mǎ"} {
+		got := make(map[string][]string)
+		for p, entries := range d.GetByPinyinGrouped(in) {
+			for _, e := range entries {
+				got[p] = append(got[p], e.Simplified)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("'%s' - got %v (want %v)", in, got, want)
+		}
+	}
+}
+
+func TestReadingsOf(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"樂 乐 [Le4] /surname Le/",
+		"樂 乐 [le4] /happy/cheerful/to laugh/",
+		"樂 乐 [yue4] /music/",
+		"樂 乐 [Yue4] /surname Yue/",
+	)...)
+
+	tests := map[string][]string{
+		"行":  {"hang2", "xing2"},
+		"乐":  {"le4", "yue4"},
+		"樂":  {"le4", "yue4"},
+		"中":  {"zhong1"},
+		"中文": nil,
+		"x":  nil,
+	}
+	for in, want := range tests {
+		got := d.ReadingsOf(in)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("'%s' - got %v (want %v)", in, got, want)
+		}
+	}
+}
+
+func TestHomophones(t *testing.T) {
+	d := parseTestDict(t,
+		"是 是 [shi4] /is/are/am/yes/to be/",
+		"事 事 [shi4] /matter/thing/item/",
+		"十 十 [shi2] /ten/",
+		"事情 事情 [shi4 qing5] /affair/matter/thing/",
+		"中 中 [zhong1] /within/among/in/middle/center/",
+	)
+
+	tests := []struct {
+		char      string
+		matchTone bool
+		want      []string
+	}{
+		{"是", true, []string{"事"}},
+		{"是", false, []string{"事", "十"}},
+		{"十", true, nil},
+		{"十", false, []string{"是", "事"}},
+		{"中", false, nil},
+		{"否", false, nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, e := range d.Homophones(test.char, test.matchTone) {
+			got = append(got, e.Simplified)
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("'%s' (%v) - got %v (want %v)", test.char, test.matchTone, got, test.want)
+		}
+	}
+}
+
+func TestContainingChar(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"學習中文 学习中文 [xue2 xi2 zhong1 wen2] /to study Chinese/",
+	)...)
+
+	tests := map[string][]string{
+		"学":  {"大学", "学校", "学生", "学习中文"},
+		"學":  {"大学", "学校", "学生", "学习中文"},
+		"文":  {"中文", "学习中文"},
+		"学生": nil,
+		"否":  nil,
+	}
+	for in, want := range tests {
+		var got []string
+		for _, e := range d.ContainingChar(in) {
+			got = append(got, e.Simplified)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("'%s' - got %v (want %v)", in, got, want)
+		}
+	}
+}
+
+func TestWordsWithPrefixSuffix(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	tests := []struct {
+		in     string
+		prefix bool
+		want   []string
+	}{
+		{"学", true, []string{"学校", "学生"}},
+		{"学", false, []string{"大学"}},
+		{"學", true, []string{"学校", "学生"}},
+		{"中国", true, []string{"中国人"}},
+		{"人", false, []string{"中国人", "美国人"}},
+		{"", true, nil},
+	}
+	for _, test := range tests {
+		entries := d.WordsWithSuffix(test.in)
+		if test.prefix {
+			entries = d.WordsWithPrefix(test.in)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Simplified)
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("'%s' (prefix %v) - got %v (want %v)", test.in, test.prefix, got, test.want)
+		}
+	}
+}
+
+func TestSearchPinyinFuzzy(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	// score returns the score of the first result for the hanzi
+	score := func(results []ScoredEntry, hanzi string) float64 {
+		for _, r := range results {
+			if r.Entry.Simplified == hanzi {
+				return r.Score
+			}
+		}
+		return 0
+	}
+
+	tests := []struct {
+		in    string
+		exact bool
+	}{
+		{"zhongwen", true},
+		{"Zhōngwén", true},
+		{"zhong1 wen2", true},
+		{"zhongwn", false},
+		{"zhongwem", false},
+		{"zhong4 wen2", false},
+		{"zhōngwn", false},
+	}
+	for _, tt := range tests {
+		results := d.SearchPinyinFuzzy(tt.in)
+		got := score(results, "中文")
+		if got <= 0 || got > 1 || (got == 1) != tt.exact {
+			t.Errorf("'%s' - got score %v (want exact %v)", tt.in, got, tt.exact)
+		}
+		if results[0].Entry.Simplified != "中文" {
+			t.Errorf("'%s' - got first %v (want 中文)", tt.in, results[0].Entry)
+		}
+		for i := 1; i < len(results); i++ {
+			if results[i].Score > results[i-1].Score {
+				t.Errorf("'%s' - results not sorted by score", tt.in)
+			}
+		}
+	}
+
+	// closer matches score higher
+	typo := score(d.SearchPinyinFuzzy("zhongwn"), "中文")
+	tone := score(d.SearchPinyinFuzzy("zhōngwn"), "中文")
+	wrong := score(d.SearchPinyinFuzzy("zhòngwn"), "中文")
+	if !(typo == tone && tone > wrong) {
+		t.Errorf("got scores %v, %v, %v (want plain == toned > wrong tone)", typo, tone, wrong)
+	}
+
+	// too many typos
+	for _, in := range []string{"", "zhxngwxx", "xxxxxxxx", "123"} {
+		if got := d.SearchPinyinFuzzy(in); score(got, "中文") != 0 {
+			t.Errorf("'%s' - got %v (want no 中文)", in, got)
+		}
+	}
+}
+
+func TestGetByPinyinRanked(t *testing.T) {
+	d := parseTestDict(t,
+		"媽 妈 [ma1] /mother/",
+		"麻 麻 [ma2] /hemp/",
+		"馬 马 [ma3] /horse/",
+		"罵 骂 [ma4] /to scold/",
+		"嗎 吗 [ma5] /(question particle)/",
+		"中文 中文 [Zhong1 wen2] /Chinese language/",
+	)
+
+	tests := []struct {
+		in    string
+		first string
+	}{
+		{"ma3", "马"},
+		{"mǎ", "马"},
+		{"ma4", "骂"},
+		{"MA5", "吗"},
+		{"ma", "妈"},
+	}
+	for _, test := range tests {
+		entries := d.GetByPinyinRanked(test.in)
+		if len(entries) != 5 {
+			t.Errorf("'%s' - got %d (want 5)", test.in, len(entries))
+			continue
+		}
+		if entries[0].Simplified != test.first {
+			t.Errorf("'%s' - got '%s' (want '%s')", test.in, entries[0].Simplified, test.first)
+		}
+	}
+
+	entries := d.GetByPinyinRanked("zhong1 wen4")
+	if len(entries) != 1 || entries[0].Simplified != "中文" {
+		t.Errorf("'zhong1 wen4' - expected 中文 ignoring tones")
+	}
+}
+
+func TestGetByPinyinWildcard(t *testing.T) {
+	d := parseTestDict(t,
+		"中文 中文 [Zhong1 wen2] /Chinese language/",
+		"中問 中问 [zhong1 wen4] /not a real word/",
+		"種聞 种闻 [zhong3 wen2] /not a real word/",
+		"中國 中国 [Zhong1 guo2] /China/",
+	)
+
+	tests := map[string][]string{
+		"zhong1 wen?": {"中文", "中问"},
+		"zhong? wen2": {"中文", "种闻"},
+		"zhong?wen?":  {"中文", "中问", "种闻"},
+		"zhōng wen?":  {"中文", "中问"},
+		"zhong? guo?": {"中国"},
+		"zhong? wen":  nil,
+		"zhong?":      nil,
+	}
+	for in, want := range tests {
+		entries := d.GetByPinyin(in)
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Simplified)
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("'%s' - got %v (want %v)", in, got, want)
+		}
+	}
+}
+
+func TestGetByPinyinSeparatedTones(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	for _, in := range []string{"zhong 1 wen 2", "zhong1wen2", " zhong  1wen 2 ", "Zhong 1 wen2"} {
+		entries := d.GetByPinyin(in)
+		if len(entries) != 1 || entries[0].Simplified != "中文" {
+			t.Errorf("'%s' - got %v (want 中文)", in, entries)
+		}
+	}
+
+	// detected as pinyin by Search
+	if got := d.Search("zhong 1 wen 2"); len(got) != 1 || got[0].Simplified != "中文" {
+		t.Errorf("got %v (want 中文)", got)
+	}
+}
+
+func TestMeaning(t *testing.T) {
+	d := New()
+	elements := d.GetByMeaning("Chinese Language")
+	for _, e := range elements[:1] {
+		t.Logf("ByMeaning: %s\n", e.Marshal())
+	}
+	if len(elements) < 1 || elements[0].Pinyin != "Zhong1 wen2" {
+		t.Fail()
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	s := `# CC-CEDICT
+# Community maintained free Chinese-English dictionary.
+#
+# Published by MDBG
+#
+# License:
+# Creative Commons Attribution-ShareAlike 4.0 International License
+# https://creativecommons.org/licenses/by-sa/4.0/
+#
+# Referenced works:
+# CEDICT - Copyright (C) 1997, 1998 Paul Andrew Denisowski
+#
+# CC-CEDICT can be downloaded from:
+# https://www.mdbg.net/chinese/dictionary?page=cc-cedict
+#
+# Additions and corrections can be sent through:
+# https://cc-cedict.org/editor/editor.php
+#
+# For more information about CC-CEDICT see:
+# https://cc-cedict.org/wiki/
+#
+#! version=123
+#! subversion=456
+#! format=ts
+#! charset=UTF-8
+#! entries=4
+#! publisher=MDBG
+#! license=https://creativecommons.org/licenses/by-sa/4.0/
+#! date=2020-02-14T06:15:46Z
+#! time=1581660946
+% % [pa1] /percent (Tw)/
+21三體綜合症 21三体综合症 [er4 shi2 yi1 san1 ti3 zong1 he2 zheng4] /trisomy/Down's syndrome/
+3C 3C [san1 C] /abbr. for computers, communications, and consumer electronics/China Compulsory Certificate (CCC)/
+3P 3P [san1 P] /(slang) threesome/`
+	r := strings.NewReader(s)
+	d, err := Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := d.Metadata()
+	if md.Version != 123 {
+		t.Errorf("version != 123")
+	}
+	if md.Subversion != 456 {
+		t.Errorf("subversion != 456")
+	}
+	if md.Format != "ts" {
+		t.Errorf("format != ts")
+	}
+	if md.Charset != "UTF-8" {
+		t.Errorf("charset != UTF-8")
+	}
+	if md.Entries != 4 {
+		t.Errorf("entries != 4")
+	}
+	if md.Publisher != "MDBG" {
+		t.Errorf("publisher != MDBG")
+	}
+	if md.License != "https://creativecommons.org/licenses/by-sa/4.0/" {
+		t.Errorf("license != https://creativecommons.org/licenses/by-sa/4.0/")
+	}
+	if md.Timestamp.Format(time.RFC3339) != "2020-02-14T06:15:46Z" {
+		t.Errorf("date != 2020-02-14T06:15:46Z")
+	}
+	if md.Timestamp.Unix() != 1581660946 {
+		t.Errorf("time != 1581660946")
+	}
+	if md.Time != 1581660946 {
+		t.Errorf("got time %d (want 1581660946)", md.Time)
+	}
+}
+
+func TestMetadataString(t *testing.T) {
+	md := Metadata{
+		Version:    123,
+		Subversion: 456,
+		Entries:    4,
+		Publisher:  "MDBG",
+		License:    "https://creativecommons.org/licenses/by-sa/4.0/",
+		Timestamp:  time.Date(2020, 2, 14, 6, 15, 46, 0, time.UTC),
+	}
+	if got, want := md.String(), "CC-CEDICT v123.456 (2020-02-14), 4 entries, MDBG, CC-BY-SA"; got != want {
+		t.Errorf("got '%s' (want '%s')", got, want)
+	}
+
+	// unset values are omitted
+	md = Metadata{Entries: 2, License: "public domain"}
+	if got, want := md.String(), "CC-CEDICT, 2 entries, public domain"; got != want {
+		t.Errorf("got '%s' (want '%s')", got, want)
+	}
+	if got := fmt.Sprint(Metadata{Version: 1}); !strings.Contains(got, "v1.0") || !strings.Contains(got, "0 entries") {
+		t.Errorf("got '%s' (want version and entry count)", got)
+	}
+}
+
+func TestMetadataTime(t *testing.T) {
+	entry := "\n中 中 [zhong1] /middle/"
+
+	// time alone, or matching the date
+	for _, header := range []string{
+		"#! entries=1\n#! time=1581660946",
+		"#! entries=1\n#! date=2020-02-14T06:15:46Z\n#! time=1581660946",
+		"#! entries=1\n#! time=1581660946\n#! date=2020-02-14T06:15:46Z",
+	} {
+		d, err := ParseString(header + entry)
+		if err != nil {
+			t.Fatalf("%q: %v", header, err)
+		}
+		if d.Metadata().Time != 1581660946 {
+			t.Errorf("%q: got time %d (want 1581660946)", header, d.Metadata().Time)
+		}
+	}
+
+	// date and time disagree
+	header := "#! entries=1\n#! date=2020-02-14T06:15:46Z\n#! time=1581660000"
+	if _, err := ParseString(header + entry); !errors.Is(err, ErrParse) ||
+		!strings.Contains(err.Error(), "time (1581660000) != date (2020-02-14T06:15:46Z)") {
+		t.Errorf("got '%v' (want time mismatch)", err)
+	}
+	if _, _, err := ParseHeader(strings.NewReader(header)); !errors.Is(err, ErrParse) {
+		t.Errorf("ParseHeader - got '%v' (want time mismatch)", err)
+	}
+
+	// invalid time
+	if _, err := ParseString("#! entries=1\n#! time=yesterday" + entry); !errors.Is(err, ErrParse) {
+		t.Errorf("got '%v' (want ErrParse)", err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := map[string]string{
+		"#! version=\n":                 "expected number",
+		"#! subversion=abc\n":           "expected number",
+		"#! entries=a1 \n":              "expected number",
+		"#! date=2020-0214T06:15:46Z\n": "expected RFC3339",
+		"#! entries=1\n":                "loaded entries (0)",
+		"% % [pa1 /percent (Tw)/\n":     "unmarshal: ",
+	}
+	for s, wantErr := range tests {
+		r := strings.NewReader(s)
+		d, err := Parse(r)
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			var md Metadata
+			if d != nil {
+				md = d.Metadata()
+			}
+			t.Errorf("got '%v', want '%s'\n%v", err, wantErr, md)
+		}
+	}
+}
+
+func TestParseLineNumber(t *testing.T) {
+	s := "# CC-CEDICT\n" +
+		"#! entries=2\n" +
+		"中 中 [Zhong1] /China/Chinese/surname Zhong/\n" +
+		"% % [pa1 /percent (Tw)/\n"
+	_, err := ParseString(s)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "line 4: unmarshal: ") {
+		t.Errorf("got '%v', want 'line 4: unmarshal: '", err)
+	}
+}
+
+func TestParseParallel(t *testing.T) {
+	lines := []string{
+		"中 中 [Zhong1] /China/Chinese/surname Zhong/",
+		"中國人 中国人 [Zhong1 guo2 ren2] /Chinese person/",
+		"人 人 [ren2] /person/people/",
+		"美國人 美国人 [Mei3 guo2 ren2] /American/",
+		"學 学 [xue2] /to learn/to study/",
+	}
+	s := fmt.Sprintf("#! entries=%d\n%s", len(lines), strings.Join(lines, "\n"))
+	for workers := 1; workers <= len(lines)+1; workers++ {
+		d, err := parse(strings.NewReader(s), workers)
+		if err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+		for i, e := range d.e {
+			if e.Marshal() != lines[i] {
+				t.Errorf("workers=%d: [%d] got '%s' (want '%s')", workers, i, e.Marshal(), lines[i])
+			}
+		}
+	}
+
+	// first invalid line is reported
+	s = "#! entries=4\n" + lines[0] + "\nbad1\n" + lines[1] + "\nbad2"
+	for workers := 1; workers <= 4; workers++ {
+		_, err := parse(strings.NewReader(s), workers)
+		if err == nil || !strings.Contains(err.Error(), "line 3: unmarshal: bad1") {
+			t.Errorf("workers=%d: got '%v', want 'line 3: unmarshal: bad1'", workers, err)
+		}
+	}
+}
+
+func TestGetByMeaningParallel(t *testing.T) {
+	meanings := []string{"person", "Chinese", "chinese person", "language", "to learn", "people"}
+	var entries []*Entry
+	for i := 0; i < parallelSearchMin+100; i++ {
+		entries = append(entries, &Entry{
+			Traditional: "人",
+			Simplified:  "人",
+			Pinyin:      fmt.Sprintf("ren%d", i%5+1),
+			Meanings:    []string{meanings[i%len(meanings)], meanings[(i*7)%len(meanings)]},
+		})
+	}
+
+	// matches are identical for any number of workers
+	for _, q := range []string{"chinese person", "people", "learn", "nothing"} {
+		want := matchMeanings(entries, q, 1)
+		for _, workers := range []int{2, 3, 8, len(entries) + 1} {
+			if got := matchMeanings(entries, q, workers); !reflect.DeepEqual(got, want) {
+				t.Errorf("'%s' workers=%d: got %d matches (want %d)", q, workers, len(got), len(want))
+			}
+		}
+	}
+
+	// large dicts give the same results as the serial scan
+	d := NewFromEntries(entries, Metadata{})
+	want := matchMeanings(entries, "chinese person", 1)
+	got := d.GetByMeaning("Chinese person")
+	if len(got) != MaxResults {
+		t.Fatalf("got %d entries (want %d)", len(got), MaxResults)
+	}
+	sort.SliceStable(want, func(i, j int) bool { return want[i].ld < want[j].ld })
+	for i, e := range got {
+		if e != want[i].e {
+			t.Errorf("[%d] got entry %p (want %p)", i, e, want[i].e)
+		}
+	}
+}
+
+func TestEntry(t *testing.T) {
+
+	equal := func(s string, e *Entry) error {
+		o := &Entry{}
+		if err := o.Unmarshal(s); err != nil {
+			return err
+		}
+		if o.Traditional != e.Traditional {
+			return fmt.Errorf("'%s' != '%s'", o.Traditional, e.Traditional)
+		}
+		if o.Simplified != e.Simplified {
+			return fmt.Errorf("'%s' != '%s'", o.Simplified, e.Simplified)
+		}
+		if o.Pinyin != e.Pinyin {
+			return fmt.Errorf("'%s' != '%s'", o.Pinyin, e.Pinyin)
+		}
+		for i := range o.Meanings {
+			if o.Meanings[i] != e.Meanings[i] {
+				return fmt.Errorf("[%d] '%s' != '%s'", i, o.Meanings[i], e.Meanings[i])
+			}
+		}
+		return nil
+	}
+
+	if err := equal("中 中 [Zhong1] /China/Chinese/surname Zhong/", &Entry{
+		Traditional: "中",
+		Simplified:  "中",
 		Pinyin:      "Zhong1",
 		Meanings: []string{
 			"China", "Chinese", "surname Zhong",
@@ -289,26 +1828,823 @@ func TestEntry(t *testing.T) {
 		t.Error(err)
 	}
 
-	if err := equal("中國人 中国人 [Zhong1 guo2 ren2] /Chinese person/", &Entry{
-		Traditional: "中國人",
-		Simplified:  "中国人",
-		Pinyin:      "Zhong1 guo2 ren2",
-		Meanings: []string{
-			"Chinese person",
-		},
-	}); err != nil {
-		t.Error(err)
+	if err := equal("中國人 中国人 [Zhong1 guo2 ren2] /Chinese person/", &Entry{
+		Traditional: "中國人",
+		Simplified:  "中国人",
+		Pinyin:      "Zhong1 guo2 ren2",
+		Meanings: []string{
+			"Chinese person",
+		},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := equal("美國人 美国人 [Mei3 guo2 ren2] /American/American person/American people/CL:個|个[ge4]/", &Entry{
+		Traditional: "美國人",
+		Simplified:  "美国人",
+		Pinyin:      "Mei3 guo2 ren2",
+		Meanings: []string{
+			"American", "American person", "American people", "CL:個|个[ge4]",
+		},
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEntryWhitespace(t *testing.T) {
+	tests := []string{
+		"龍豆\t龙豆 [long2 dou4] /dragon bean/long bean/",
+		"龍豆  龙豆  [long2 dou4] /dragon bean/long bean/",
+		"龍豆\t\t龙豆\t[long2 dou4] /dragon bean/long bean/",
+		"  龍豆 龙豆 [long2 dou4] /dragon bean/long bean/",
+	}
+	for _, s := range tests {
+		e := &Entry{}
+		if err := e.Unmarshal(s); err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if e.Traditional != "龍豆" || e.Simplified != "龙豆" {
+			t.Errorf("%q: got '%s' '%s' (want '龍豆' '龙豆')", s, e.Traditional, e.Simplified)
+		}
+		if e.Pinyin != "long2 dou4" {
+			t.Errorf("%q: got pinyin '%s' (want 'long2 dou4')", s, e.Pinyin)
+		}
+		if len(e.Meanings) != 2 {
+			t.Errorf("%q: got %d meanings (want 2)", s, len(e.Meanings))
+		}
+	}
+}
+
+func TestEntryBrackets(t *testing.T) {
+	tests := []struct {
+		s          string
+		trad, simp string
+		pinyin     string
+		meanings   int
+	}{
+		{"並 并 [bing4] /and/see also 並|并[bing1]/", "並", "并", "bing4", 2},
+		{"[中] [中] [zhong1] /bracketed hanzi/", "[中]", "[中]", "zhong1", 1},
+		{"中] 中] [zhong1] /closing bracket/", "中]", "中]", "zhong1", 1},
+		{"A/B A/B [A B] /A or B/", "A/B", "A/B", "A B", 1},
+		{"卡拉OK 卡拉OK [ka3 la1 O K] /karaoke/", "卡拉OK", "卡拉OK", "ka3 la1 O K", 1},
+	}
+	for _, test := range tests {
+		e := &Entry{}
+		if err := e.Unmarshal(test.s); err != nil {
+			t.Errorf("%q: %v", test.s, err)
+			continue
+		}
+		if e.Traditional != test.trad || e.Simplified != test.simp {
+			t.Errorf("%q: got '%s' '%s' (want '%s' '%s')", test.s,
+				e.Traditional, e.Simplified, test.trad, test.simp)
+		}
+		if e.Pinyin != test.pinyin {
+			t.Errorf("%q: got pinyin '%s' (want '%s')", test.s, e.Pinyin, test.pinyin)
+		}
+		if len(e.Meanings) != test.meanings {
+			t.Errorf("%q: got %d meanings (want %d)", test.s, len(e.Meanings), test.meanings)
+		}
+		if e.Marshal() != test.s {
+			t.Errorf("%q: got '%s' from Marshal", test.s, e.Marshal())
+		}
+	}
+}
+
+func TestEntryClone(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	c := d.GetByHanzi("中文").Clone()
+	c.Pinyin = "zhong1 wen2"
+	c.Meanings[0] = "changed"
+	c.Meanings = append(c.Meanings, "added")
+
+	e := d.GetByHanzi("中文")
+	if e == c {
+		t.Fatal("expected a copy of the entry")
+	}
+	if e.Pinyin != "Zhong1 wen2" || len(e.Meanings) != 1 || e.Meanings[0] != "Chinese language" {
+		t.Errorf("dict entry was modified: %s", e.Marshal())
+	}
+}
+
+func TestEntryInvalid(t *testing.T) {
+	tests := map[string]string{
+		"中 中 [zhong1]":           "expected '/meanings/'",
+		"中 中 [zhong1] /":         "expected '/meanings/'",
+		"中 中 [zhong1] //":        "expected '/meanings/'",
+		"中 中 [zhong1] / /":       "expected '/meanings/'",
+		"中 中 ]zhong1[ /China/":   "expected '[pinyin]'",
+		"中 中 [zhong1 /China/":    "expected '[pinyin]'",
+		"中 [zhong1] /China/":     "expected two hanzi",
+		"中 中 中 [zhong1] /China/": "expected two hanzi",
+	}
+	for s, wantErr := range tests {
+		e := &Entry{}
+		err := e.Unmarshal(s)
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: got '%v', want '%s'", s, err, wantErr)
+		}
+	}
+}
+
+func TestSplitMeanings(t *testing.T) {
+	tests := map[string][]string{
+		"/a/b/":         {"a", "b"},
+		"//a//":         {"a"},
+		"/a//b/":        {"a", "b"},
+		"a/b":           {"a", "b"},
+		" /a b/c/ ":     {"a b", "c"},
+		"/CL:個|个[ge4]/": {"CL:個|个[ge4]"},
+		"/":             nil,
+		"":              nil,
+	}
+	for in, want := range tests {
+		if got := SplitMeanings(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q - got %q (want %q)", in, got, want)
+		}
+	}
+
+	// unmarshal ignores empty meanings
+	e := &Entry{}
+	if err := e.Unmarshal("中 中 [zhong1] //within//among/"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"within", "among"}; !reflect.DeepEqual(e.Meanings, want) {
+		t.Errorf("got %q (want %q)", e.Meanings, want)
+	}
+}
+
+func TestJoinMeanings(t *testing.T) {
+	got, err := JoinMeanings([]string{"a", "b c"})
+	if err != nil || got != "/a/b c/" {
+		t.Errorf("got '%s', %v (want '/a/b c/')", got, err)
+	}
+
+	// meanings round trip through SplitMeanings
+	meanings := []string{"China", "CL:個|个[ge4]"}
+	if got, _ := JoinMeanings(meanings); !reflect.DeepEqual(SplitMeanings(got), meanings) {
+		t.Errorf("got %q (want %q)", SplitMeanings(got), meanings)
+	}
+
+	// meanings containing slashes are rejected
+	if _, err := JoinMeanings([]string{"a", "either/or"}); err == nil {
+		t.Error("expected error for meaning containing '/'")
+	}
+
+	// and can't be saved, as they would be split when loaded
+	dir := t.TempDir()
+	d := NewFromEntries([]*Entry{
+		{Traditional: "或", Simplified: "或", Pinyin: "huo4", Meanings: []string{"either/or"}},
+	}, Metadata{})
+	if err := d.Save(filepath.Join(dir, "slash.txt")); err == nil {
+		t.Error("expected error saving meaning containing '/'")
+	}
+}
+
+func TestEntryValidate(t *testing.T) {
+	valid := &Entry{
+		Traditional: "中國人",
+		Simplified:  "中国人",
+		Pinyin:      "Zhong1 guo2 ren2",
+		Meanings:    []string{"Chinese person"},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid entry: %v", err)
+	}
+
+	tests := map[string]*Entry{
+		"expected traditional and simplified": {
+			Simplified: "中国人", Pinyin: "Zhong1 guo2 ren2", Meanings: []string{"Chinese person"},
+		},
+		"expected hanzi characters": {
+			Traditional: "3C", Simplified: "3C", Pinyin: "san1 C", Meanings: []string{"3C"},
+		},
+		"expected pinyin": {
+			Traditional: "中國人", Simplified: "中国人", Meanings: []string{"Chinese person"},
+		},
+		"pinyin syllables (2) != hanzi characters (3)": {
+			Traditional: "中國人", Simplified: "中国人", Pinyin: "Zhong1 guo2", Meanings: []string{"Chinese person"},
+		},
+		"traditional characters (2) != simplified characters (3)": {
+			Traditional: "中國", Simplified: "中国人", Pinyin: "Zhong1 guo2 ren2", Meanings: []string{"Chinese person"},
+		},
+		"expected at least one meaning": {
+			Traditional: "中國人", Simplified: "中国人", Pinyin: "Zhong1 guo2 ren2",
+		},
+		"expected non-empty meanings": {
+			Traditional: "中國人", Simplified: "中国人", Pinyin: "Zhong1 guo2 ren2", Meanings: []string{" "},
+		},
+		"unexpected '/' in meaning": {
+			Traditional: "中國人", Simplified: "中国人", Pinyin: "Zhong1 guo2 ren2", Meanings: []string{"Chinese/person"},
+		},
+	}
+	for wantErr, e := range tests {
+		err := e.Validate()
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("got '%v', want '%s'", err, wantErr)
+		}
+	}
+}
+
+func TestDictValidate(t *testing.T) {
+	d := parseTestDict(t,
+		"中國人 中国人 [Zhong1 guo2 ren2] /Chinese person/",
+		"美國人 美国人 [Mei3 guo2] /American/",
+		"3C 3C [san1 C] /abbr. for computers, communications, and consumer electronics/",
+		"21三體綜合症 21三体综合症 [er4 shi2 yi1 san1 ti3 zong1 he2 zheng4] /trisomy/Down's syndrome/",
+	)
+	errs := d.Validate()
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors (want 1): %v", len(errs), errs)
+	}
+	if !strings.Contains(errs[0].Error(), "美國人") {
+		t.Errorf("got '%v', want error for 美國人", errs[0])
+	}
+}
+
+func TestNewFromEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	md := Metadata{
+		Version:   1,
+		Format:    "ts",
+		Entries:   99,
+		Timestamp: time.Date(2020, 5, 1, 12, 30, 0, 0, time.UTC),
+	}
+	d := NewFromEntries([]*Entry{
+		{Traditional: "中國", Simplified: "中国", Pinyin: "Zhong1 guo2", Meanings: []string{"China"}},
+		nil,
+		{Traditional: "人", Simplified: "人", Pinyin: "ren2", Meanings: []string{"person", "people"}},
+		{Traditional: "中國人", Simplified: "中国人", Pinyin: "Zhong1 guo2 ren2", Meanings: []string{"Chinese person"}},
+	}, md)
+
+	// ready without blocking, with indexes built
+	if !d.Ready() || d.Err() != nil {
+		t.Fatalf("expected ready dict, got %v", d.Err())
+	}
+	if got := d.Metadata().Entries; got != 3 {
+		t.Errorf("got %d entries (want 3)", got)
+	}
+	if e := d.GetByHanzi("中国人"); e == nil || e.Pinyin != "Zhong1 guo2 ren2" {
+		t.Errorf("GetByHanzi - got %v", e)
+	}
+	if got := d.GetByMeaning("people"); len(got) != 1 || got[0].Simplified != "人" {
+		t.Errorf("GetByMeaning - got %v (want 人)", got)
+	}
+	if got, want := d.HanziToPinyin("中国人人"), "Zhong1guo2ren2 ren2"; got != want {
+		t.Errorf("HanziToPinyin - got '%s' (want '%s')", got, want)
+	}
+
+	// saved with a header generated from the metadata
+	filename := filepath.Join(dir, "entries.txt")
+	if err := d.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	dict, err := Load(filename)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if dict.Metadata() != d.Metadata() {
+		t.Errorf("metadata - got %+v (want %+v)", dict.Metadata(), d.Metadata())
+	}
+}
+
+func TestFilter(t *testing.T) {
+	dir := t.TempDir()
+
+	d := parseTestDict(t,
+		"中 中 [Zhong1] /China/Chinese/surname Zhong/",
+		"中國人 中国人 [Zhong1 guo2 ren2] /Chinese person/",
+		"人 人 [ren2] /person/people/",
+		"美國人 美国人 [Mei3 guo2 ren2] /American/",
+	)
+	single := d.Filter(func(e *Entry) bool {
+		return len([]rune(e.Simplified)) == 1
+	})
+	if single.Metadata().Entries != 2 {
+		t.Fatalf("got %d entries (want 2)", single.Metadata().Entries)
 	}
 
-	if err := equal("美國人 美国人 [Mei3 guo2 ren2] /American/American person/American people/CL:個|个[ge4]/", &Entry{
-		Traditional: "美國人",
-		Simplified:  "美国人",
-		Pinyin:      "Mei3 guo2 ren2",
-		Meanings: []string{
-			"American", "American person", "American people", "CL:個|个[ge4]",
-		},
-	}); err != nil {
-		t.Error(err)
+	// save filtered dict and reload it
+	filename := filepath.Join(dir, "filter.txt")
+	if err := single.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	dict, err := Load(filename)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if dict.Metadata() != single.Metadata() {
+		t.Errorf("metadata mismatch")
+	}
+	if dict.GetByHanzi("人") == nil || dict.GetByHanzi("中国人") != nil {
+		t.Errorf("expected only single character entries")
+	}
+}
+
+func TestSetMetadata(t *testing.T) {
+	dir := t.TempDir()
+
+	d := parseTestDict(t, testEntries...)
+	d.header = []string{
+		"# CC-CEDICT",
+		"#! version=1",
+		"#! entries=99",
+		"#! publisher=MDBG",
+		"#! custom=kept",
+		"# trailing comment",
+	}
+	filtered := d.Filter(func(e *Entry) bool { return e.Simplified == "人" })
+
+	md := filtered.Metadata()
+	md.Version = 2
+	md.Publisher = "Example Publisher"
+	md.Timestamp = time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	filtered.SetMetadata(md)
+
+	filename := filepath.Join(dir, "metadata.txt")
+	if err := filtered.SaveWithLineEnding(filename, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# CC-CEDICT\n" +
+		"#! version=2\n" +
+		"#! entries=1\n" +
+		"#! publisher=Example Publisher\n" +
+		"#! date=2021-03-04T05:06:07Z\n" +
+		"#! custom=kept\n" +
+		"# trailing comment\n" +
+		"人 人 [ren2] /person/people/CL:個|个[ge4],位[wei4]/"
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	// saved metadata is loaded again
+	loaded, err := Load(filename)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if loaded.Metadata() != filtered.Metadata() {
+		t.Errorf("got %+v (want %+v)", loaded.Metadata(), filtered.Metadata())
+	}
+
+	// the original dict is unchanged
+	if d.Metadata().Version != 0 || len(d.header) != 6 {
+		t.Errorf("original dict metadata changed")
+	}
+}
+
+func TestSaveWithLineEnding(t *testing.T) {
+	dir := t.TempDir()
+
+	d := parseTestDict(t, testEntries...)
+	filename := filepath.Join(dir, "lf.txt")
+	if err := d.SaveWithLineEnding(filename, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("\r")) {
+		t.Error("expected no carriage returns")
+	}
+	if n := bytes.Count(b, []byte("\n")); n != len(testEntries) {
+		t.Errorf("got %d line endings (want %d)", n, len(testEntries))
+	}
+
+	// reload saved entries
+	dict, err := Load(filename)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(dict.e) != len(testEntries) {
+		t.Errorf("got %d entries (want %d)", len(dict.e), len(testEntries))
+	}
+
+	// default remains CRLF
+	filename = filepath.Join(dir, "crlf.txt")
+	if err := d.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filename); bytes.Count(b, []byte("\r\n")) != len(testEntries) {
+		t.Error("expected CRLF line endings")
+	}
+}
+
+func TestSaveComments(t *testing.T) {
+	dir := t.TempDir()
+
+	s := strings.Join([]string{
+		"# CC-CEDICT",
+		"#! entries=3",
+		"中 中 [zhong1] /within/among/",
+		"# entries below were added by hand",
+		"#   and reviewed",
+		"中國 中国 [Zhong1 guo2] /China/",
+		"人 人 [ren2] /person/",
+		"# end of file",
+	}, "\n")
+	d, err := ParseString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.header) != 2 {
+		t.Errorf("got %d header lines (want 2)", len(d.header))
+	}
+
+	// comments are saved in their original position
+	filename := filepath.Join(dir, "comments.txt")
+	if err := d.SaveWithLineEnding(filename, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != s {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", b, s)
+	}
+}
+
+func TestSaveSubset(t *testing.T) {
+	dir := t.TempDir()
+
+	d := parseTestDict(t, testEntries...)
+	pred := func(e *Entry) bool {
+		return strings.Contains(e.Pinyin, "ren2")
+	}
+	want := len(d.Filter(pred).e)
+	if want == 0 || want == len(testEntries) {
+		t.Fatalf("expected a partial subset, got %d entries", want)
+	}
+
+	filename := filepath.Join(dir, "subset.txt.gz")
+	if err := d.SaveSubset(filename, pred); err != nil {
+		t.Fatal(err)
+	}
+	dict, err := Load(filename)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if dict.Metadata().Entries != want || len(dict.e) != want {
+		t.Errorf("got %d entries (want %d)", len(dict.e), want)
+	}
+	for _, e := range dict.e {
+		if !pred(e) {
+			t.Errorf("unexpected entry %s", e.Marshal())
+		}
+	}
+}
+
+func TestIdioms(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"畫蛇添足 画蛇添足 [hua4 she2 tian1 zu2] /lit. draw legs on a snake (idiom)/",
+		"一言既出，駟馬難追 一言既出，驷马难追 [yi1 yan2 ji4 chu1 , si4 ma3 nan2 zhui1] /a promise is a promise (idiom)/",
+		"成語 成语 [cheng2 yu3] /Chinese set expression, often made up of 4 characters/idiom/",
+	)...)
+
+	idioms := d.Idioms()
+	if len(idioms) != 2 {
+		t.Fatalf("got %d idioms (want 2)", len(idioms))
+	}
+	if idioms[0].Traditional != "畫蛇添足" {
+		t.Errorf("got %s (want 畫蛇添足)", idioms[0].Traditional)
+	}
+
+	four := d.FourCharIdioms()
+	if len(four) != 1 || four[0].Traditional != "畫蛇添足" {
+		t.Errorf("got %v (want 畫蛇添足)", four)
+	}
+}
+
+func TestSingleCharEntries(t *testing.T) {
+	d := parseTestDict(t,
+		"中 中 [Zhong1] /China/Chinese/surname Zhong/",
+		"中國人 中国人 [Zhong1 guo2 ren2] /Chinese person/",
+		"人 人 [ren2] /person/people/",
+		"學 学 [xue2] /to learn/to study/",
+		"學生 学生 [xue2 sheng5] /student/",
+	)
+	entries := d.SingleCharEntries()
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries (want 3)", len(entries))
+	}
+	for _, e := range entries {
+		if len([]rune(e.Simplified)) != 1 {
+			t.Errorf("got multi-character entry '%s'", e.Simplified)
+		}
+	}
+}
+
+func TestGetByMeaningAcrossMeanings(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"火車站 火车站 [huo3 che1 zhan4] /train station/",
+		"高鐵站 高铁站 [gao1 tie3 zhan4] /high-speed rail station/bullet train/",
+	)...)
+	hanziOf := func(entries []*Entry) []string {
+		var hanzi []string
+		for _, e := range entries {
+			hanzi = append(hanzi, e.Simplified)
+		}
+		return hanzi
+	}
+
+	// words split across meanings only match with the option
+	if got := hanziOf(d.GetByMeaning("train station")); !reflect.DeepEqual(got, []string{"火车站"}) {
+		t.Errorf("got %v (want [火车站])", got)
+	}
+	got := hanziOf(d.GetByMeaning("Train Station", AcrossMeanings()))
+	if want := []string{"火车站", "高铁站"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v (want %v)", got, want)
+	}
+
+	// every word must appear
+	if got := d.GetByMeaning("train terminus", AcrossMeanings()); len(got) != 0 {
+		t.Errorf("got %v (want none)", got)
+	}
+
+	// short queries still return nothing
+	if got := d.GetByMeaning("a", AcrossMeanings()); len(got) != 0 {
+		t.Errorf("got %v (want none)", got)
+	}
+}
+
+func TestGetByMeaningMatches(t *testing.T) {
+	d := parseTestDict(t,
+		"去 去 [qu4] /to go/to leave/",
+		"走 走 [zou3] /to walk/to go/to run/",
+		"出國 出国 [chu1 guo2] /to go abroad/to leave the country/",
+	)
+
+	matches := d.GetByMeaningMatches("To Leave")
+	if len(matches) != 1 || matches[0].Entry.Simplified != "去" || matches[0].Meaning != "to leave" {
+		t.Errorf("got %+v (want 去 'to leave')", matches)
+	}
+
+	// the matched meaning is the gloss found in the query
+	want := map[string]string{"去": "to go", "走": "to go", "出国": "to go abroad"}
+	matches = d.GetByMeaningMatches("to go abroad")
+	if len(matches) != len(want) {
+		t.Fatalf("got %d matches (want %d)", len(matches), len(want))
+	}
+	for _, m := range matches {
+		if m.Meaning != want[m.Entry.Simplified] {
+			t.Errorf("%s - got '%s' (want '%s')", m.Entry.Simplified, m.Meaning, want[m.Entry.Simplified])
+		}
+	}
+
+	// entries are the same as GetByMeaning
+	for i, e := range d.GetByMeaning("to go abroad") {
+		if matches[i].Entry != e {
+			t.Errorf("[%d] got %s (want %s)", i, matches[i].Entry.Simplified, e.Simplified)
+		}
+	}
+}
+
+func TestSetMinQueryLength(t *testing.T) {
+	d := parseTestDict(t,
+		"啊 啊 [a5] /a/modal particle/",
+		"一 一 [yi1] /one/a (article)/",
+		"二 二 [er4] /two/",
+	)
+
+	// single character queries are rejected by default
+	for _, q := range []string{"a", " a ", "", "啊"} {
+		if got := d.GetByMeaning(q); len(got) != 0 {
+			t.Errorf("'%s' - got %d entries (want 0)", q, len(got))
+		}
+	}
+	if got := d.GetByMeaning("two"); len(got) != 1 {
+		t.Errorf("'two' - got %d entries (want 1)", len(got))
+	}
+
+	d.SetMinQueryLength(0)
+	if got := d.GetByMeaning("a"); len(got) != 1 || got[0].Simplified != "啊" {
+		t.Errorf("'a' - got %v (want 啊)", got)
+	}
+
+	d.SetMinQueryLength(4)
+	if got := d.GetByMeaning("two"); len(got) != 0 {
+		t.Errorf("'two' - got %d entries (want 0)", len(got))
+	}
+}
+
+func TestGetByMeaningQuery(t *testing.T) {
+	d := parseTestDict(t,
+		"去 去 [qu4] /to go/to leave/",
+		"出國 出国 [chu1 guo2] /to go abroad/to leave the country/",
+		"走 走 [zou3] /to walk/to go/to run/",
+		"好 好 [hao3] /good/to be good to go/",
+		"圍棋 围棋 [wei2 qi2] /the game of go/",
+	)
+
+	tests := map[string][]string{
+		`"to go"`:              {"去", "走", "出国", "好"},
+		`"to go" -abroad`:      {"去", "走", "好"},
+		`"to go" -abroad -run`: {"去", "好"},
+		`go -to`:               {"围棋"},
+		`"go abroad" to`:       {"出国"},
+		`"abroad to"`:          nil,
+		`"TO LEAVE`:            {"去", "出国"},
+		`game go`:              {"围棋"},
+		`-go`:                  nil,
+		``:                     nil,
+	}
+	for q, want := range tests {
+		var got []string
+		for _, e := range d.GetByMeaningQuery(q) {
+			got = append(got, e.Simplified)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s - got %q (want %q)", q, got, want)
+		}
+	}
+}
+
+func TestGetByMeaningWords(t *testing.T) {
+	d := parseTestDict(t,
+		"紅 红 [hong2] /red/popular/",
+		"花 花 [hua1] /flower/blossom/",
+		"紅花 红花 [hong2 hua1] /safflower/red flower/",
+		"花紅 花红 [hua1 hong2] /flower of a red color/",
+	)
+
+	tests := []struct {
+		words    []string
+		matchAll bool
+		want     []string
+	}{
+		{[]string{"red", "flower"}, true, []string{"红花", "花红"}},
+		{[]string{"Red Flower"}, true, []string{"红花", "花红"}},
+		{[]string{"red", "flower"}, false, []string{"红花", "花红", "红", "花"}},
+		{[]string{"red", "blossom"}, true, nil},
+		{[]string{"red", "blossom"}, false, []string{"红", "花", "红花", "花红"}},
+		{nil, false, nil},
+	}
+	for _, test := range tests {
+		entries := d.GetByMeaningWords(test.words, test.matchAll)
+		if len(entries) != len(test.want) {
+			t.Errorf("%q (%v): got %d entries (want %d)",
+				test.words, test.matchAll, len(entries), len(test.want))
+			continue
+		}
+		for i, e := range entries {
+			if e.Simplified != test.want[i] {
+				t.Errorf("%q (%v): [%d] got '%s' (want '%s')",
+					test.words, test.matchAll, i, e.Simplified, test.want[i])
+			}
+		}
+	}
+}
+
+func TestEntryAt(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	if d.Len() != len(testEntries) {
+		t.Fatalf("got len %d (want %d)", d.Len(), len(testEntries))
+	}
+
+	// first entry matches the first parsed line
+	e, ok := d.EntryAt(0)
+	if !ok || e.Marshal() != testEntries[0] {
+		t.Errorf("got %v, %v (want '%s')", e, ok, testEntries[0])
+	}
+
+	// iterate over all entries
+	for i := 0; i < d.Len(); i++ {
+		e, ok := d.EntryAt(i)
+		if !ok || e.Marshal() != testEntries[i] {
+			t.Errorf("%d - got %v, %v (want '%s')", i, e, ok, testEntries[i])
+		}
+	}
+
+	// out of range
+	for _, i := range []int{-1, d.Len(), d.Len() + 1} {
+		if e, ok := d.EntryAt(i); ok || e != nil {
+			t.Errorf("%d - got %v, %v (want nil, false)", i, e, ok)
+		}
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	d := parseTestDict(t,
+		"爸 爸 [ba4] /father/",
+		"吖 吖 [a1] /phonetic a/",
+		"啊 啊 [a5] /modal particle/",
+		"嗄 嗄 [a2] /what?/",
+		"八 八 [ba1] /eight/",
+		"阿 阿 [A4] /surname/",
+		"吧 吧 [ba5] /modal particle/",
+		"綠 绿 [lu:4] /green/",
+		"路 路 [lu4] /road/",
+		"阿拉 阿拉 [a1 la1] /Allah/",
+	)
+
+	// hanzi returns the simplified hanzi of each entry
+	hanzi := func(entries []*Entry) []string {
+		got := []string{}
+		for _, e := range entries {
+			got = append(got, e.Simplified)
+		}
+		return got
+	}
+
+	// collated order is 吖 阿拉 嗄 阿 啊 八 爸 吧 路 绿
+	tests := []struct {
+		hanzi         string
+		n             int
+		before, after []string
+	}{
+		{"八", 2, []string{"阿", "啊"}, []string{"爸", "吧"}},
+		{"八", 1, []string{"啊"}, []string{"爸"}},
+		{"吖", 2, []string{}, []string{"阿拉", "嗄"}},
+		{"绿", 3, []string{"爸", "吧", "路"}, []string{}},
+		{"阿拉", 20, []string{"吖"}, []string{"嗄", "阿", "啊", "八", "爸", "吧", "路", "绿"}},
+	}
+	for _, tt := range tests {
+		before, after := d.Neighbors(d.GetByHanzi(tt.hanzi), tt.n)
+		if got := hanzi(before); !reflect.DeepEqual(got, tt.before) {
+			t.Errorf("%s %d - got before %v (want %v)", tt.hanzi, tt.n, got, tt.before)
+		}
+		if got := hanzi(after); !reflect.DeepEqual(got, tt.after) {
+			t.Errorf("%s %d - got after %v (want %v)", tt.hanzi, tt.n, got, tt.after)
+		}
+	}
+
+	// copies of entries are found by value
+	before, after := d.Neighbors(d.Safe().GetByHanzi("八"), 1)
+	if !reflect.DeepEqual(hanzi(before), []string{"啊"}) || !reflect.DeepEqual(hanzi(after), []string{"爸"}) {
+		t.Errorf("got %v, %v (want [啊], [爸])", hanzi(before), hanzi(after))
+	}
+
+	// unknown entries and invalid counts return nothing
+	unknown := &Entry{Traditional: "八", Simplified: "八", Pinyin: "ba2", Meanings: []string{"eight"}}
+	for _, e := range []*Entry{nil, unknown} {
+		if before, after := d.Neighbors(e, 2); before != nil || after != nil {
+			t.Errorf("%v - got %v, %v (want nil)", e, before, after)
+		}
+	}
+	if before, after := d.Neighbors(d.GetByHanzi("八"), 0); before != nil || after != nil {
+		t.Errorf("got %v, %v (want nil)", before, after)
+	}
+}
+
+func TestCharPinyin(t *testing.T) {
+	e := &Entry{}
+	if err := e.Unmarshal("中國人 中国人 [Zhong1 guo2 ren2] /Chinese person/"); err != nil {
+		t.Fatal(err)
+	}
+	want := []CharSyllable{{"中", "Zhong1"}, {"国", "guo2"}, {"人", "ren2"}}
+	if got := e.CharPinyin(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v (want %v)", got, want)
+	}
+
+	// mismatched counts are aligned in order
+	tests := []struct {
+		hanzi, pinyin string
+		want          []CharSyllable
+	}{
+		{"一点儿", "yi1 dian3", []CharSyllable{{"一", "yi1"}, {"点", "dian3"}, {"儿", ""}}},
+		{"么", "me5 me5", []CharSyllable{{"么", "me5 me5"}}},
+		{"", "", []CharSyllable{}},
+	}
+	for _, tt := range tests {
+		e := &Entry{Traditional: tt.hanzi, Simplified: tt.hanzi, Pinyin: tt.pinyin}
+		if got := e.CharPinyin(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("'%s' - got %v (want %v)", tt.hanzi, got, tt.want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	d := parseTestDict(t,
+		"中 中 [Zhong1] /China/Chinese/surname Zhong/",
+		"中國人 中国人 [Zhong1 guo2 ren2] /Chinese person/",
+		"人 人 [ren2] /person/people/CL:個|个[ge4],位[wei4]/",
+		"美國人 美国人 [Mei3 guo2 ren2] /American/American person/American people/CL:個|个[ge4]/",
+	)
+	st := d.Stats()
+	if st.Entries != d.Metadata().Entries {
+		t.Errorf("entries (%d) != metadata entries (%d)", st.Entries, d.Metadata().Entries)
+	}
+	if st.SingleChar+st.MultiChar != st.Entries {
+		t.Errorf("single (%d) + multi (%d) != entries (%d)", st.SingleChar, st.MultiChar, st.Entries)
+	}
+	if st.SingleChar != 2 || st.MultiChar != 2 {
+		t.Errorf("got single %d, multi %d (want 2, 2)", st.SingleChar, st.MultiChar)
+	}
+	if st.Classifiers != 2 {
+		t.Errorf("got %d classifiers (want 2)", st.Classifiers)
+	}
+	if st.Syllables != 4 {
+		t.Errorf("got %d syllables (want 4)", st.Syllables)
+	}
+	if st.AvgMeanings != 2.75 {
+		t.Errorf("got %.2f average meanings (want 2.75)", st.AvgMeanings)
 	}
 }
 
@@ -316,12 +2652,12 @@ func TestHanziToPinyin(t *testing.T) {
 	tests := map[string]string{
 		"":   "",
 		"  ": "",
-		"画儿": "Huà r",
+		"画儿": "Huàr",
 		"省略": "Shěng lüè",
 		"人民银行旁边一行人abc字母【路牌】，平行宇宙发行股票。": "Rén mín yín háng páng biān yī xíng rén abc zì mǔ [lù pái], píng xíng yǔ zhòu fā xíng gǔ piào.",
 		"我的大王！": "Wǒ de dà wáng!",
 		//"你好。我饿了。":       "Nǐ hǎo. Wǒ èle.",
-		"地址：重庆市江北区重工业？": "Dì zhǐ: chóng qìng shì jiāng běi qū zhòng gōng yè?",
+		"地址：重庆市江北区重工业？": "Dì zhǐ: Chóngqìngshì Jiāngběiqū zhòng gōng yè?",
 		//"abc123": "abc123",
 		//"*123*人": "*123* Rén",
 		//"` 1234 ~!@# $% ^&* ()_+": "` 1234 ~!@# $% ^&* ()_+",
@@ -361,6 +2697,40 @@ func TestLevenshtein(t *testing.T) {
 	}
 }
 
+func TestLevenshteinCapped(t *testing.T) {
+	words := []string{
+		"", "a", "ab", "ba", "abc", "中文老師", "中文", "中國人", "美國人",
+		"I like learning chinese.", "Do you like learning chinese?",
+		"Wǒ xǐhuān xuéxí zhōngwén.", "Nǐ xǐhuān xué zhōngwén ma?",
+		"to go", "to go abroad", "kitten", "sitting", "flaw", "lawn",
+	}
+
+	// add random strings from a small alphabet, so distances vary
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		b := make([]rune, rnd.Intn(12))
+		for j := range b {
+			b[j] = []rune("abc中")[rnd.Intn(4)]
+		}
+		words = append(words, string(b))
+	}
+
+	for _, src := range words {
+		for _, dst := range words {
+			want := levenshtein(src, dst)
+			for limit := 0; limit <= 12; limit++ {
+				got := levenshteinCapped(src, dst, limit)
+				if want <= limit && got != want {
+					t.Errorf("levenshteinCapped(%q,%q,%d) got %d, want %d", src, dst, limit, got, want)
+				}
+				if want > limit && got != limit+1 {
+					t.Errorf("levenshteinCapped(%q,%q,%d) got %d, want %d (capped)", src, dst, limit, got, limit+1)
+				}
+			}
+		}
+	}
+}
+
 func ExampleDict_getByPinyin() {
 	d := New()
 	elements := d.GetByPinyin("mei guo ren")
@@ -378,9 +2748,9 @@ func ExampleDict_hanziToPinyin() {
 	fmt.Printf("%s (tonenums)  '%s'\n", hans, d.HanziToPinyin(hans))
 	fmt.Printf("%s (tones)     '%s'\n", hans, FixSymbolSpaces(PinyinTones(d.HanziToPinyin(hans))))
 	// Output:
-	// 你喜歡學中文嗎？ (plaintext) 'Ni xi huan xue zhong wen ma ?'
-	// 你喜歡學中文嗎？ (tonenums)  'Ni3 xi3 huan5 xue2 zhong1 wen2 ma3 ?'
-	// 你喜歡學中文嗎？ (tones)     'Nǐ xǐ huan xué zhōng wén mǎ?'
+	// 你喜歡學中文嗎？ (plaintext) 'Ni xi huan xue Zhongwen ma ?'
+	// 你喜歡學中文嗎？ (tonenums)  'Ni3 xi3 huan5 xue2 Zhong1wen2 ma5 ?'
+	// 你喜歡學中文嗎？ (tones)     'Nǐ xǐ huan xué Zhōngwén ma?'
 }
 
 func BenchmarkHanziToPinyin(b *testing.B) {
@@ -390,6 +2760,9 @@ func BenchmarkHanziToPinyin(b *testing.B) {
 		s     string
 	}{
 		{"HanziToPinyin", "中國人"},
+		{"Paragraph", "我喜歡學中文。人民银行旁边一行人abc字母【路牌】，平行宇宙发行股票。" +
+			"地址：重庆市江北区重工业？我的大王！你喜歡學中文嗎？我們都是中國人。"},
+		{"LongHanzi", strings.Repeat("中國人民銀行旁边一行人", 20)},
 	}
 	for _, test := range tests {
 		b.Run(test.label, func(b *testing.B) {
@@ -400,6 +2773,68 @@ func BenchmarkHanziToPinyin(b *testing.B) {
 	}
 }
 
+func BenchmarkParse(b *testing.B) {
+	d := New()
+	if err := d.Err(); err != nil {
+		b.Skip(err)
+	}
+
+	// save full dict to be parsed
+	dir := b.TempDir()
+	filename := filepath.Join(dir, "parse.txt")
+	if err := d.Save(filename); err != nil {
+		b.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	tests := []struct {
+		label   string
+		workers int
+	}{
+		{"Serial", 1},
+		{"Parallel", runtime.GOMAXPROCS(0)},
+	}
+	for _, test := range tests {
+		b.Run(test.label, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if _, err := parse(bytes.NewReader(data), test.workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetByMeaning(b *testing.B) {
+	d := New()
+	if err := d.Err(); err != nil {
+		b.Skip(err)
+	}
+	query := "to go"
+	tests := []struct {
+		label   string
+		workers int
+	}{
+		{"Serial", 1},
+		{"Parallel", runtime.GOMAXPROCS(0)},
+	}
+	for _, test := range tests {
+		b.Run(test.label, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				matchMeanings(d.e, query, test.workers)
+			}
+		})
+	}
+	b.Run("Filtered", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			matchMeanings(d.meaningCandidates(query), query, 1)
+		}
+	})
+}
+
 func BenchmarkLevenshtein(b *testing.B) {
 	tests := []struct {
 		label    string
@@ -416,5 +2851,10 @@ func BenchmarkLevenshtein(b *testing.B) {
 				levenshtein(test.src, test.dst)
 			}
 		})
+		b.Run(test.label+"Capped", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				levenshteinCapped(test.src, test.dst, 3)
+			}
+		})
 	}
 }
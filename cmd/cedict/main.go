@@ -5,30 +5,249 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/jcramb/cedict"
 )
 
+// options holds the parsed command line flags.
+type options struct {
+	json    bool
+	repl    bool
+	trad    bool
+	simp    bool
+	tones   bool
+	numbers bool
+	limit   int
+	query   string
+}
+
+// result is an entry formatted for JSON output.
+type result struct {
+	Traditional string   `json:"traditional,omitempty"`
+	Simplified  string   `json:"simplified,omitempty"`
+	Pinyin      string   `json:"pinyin"`
+	Meanings    []string `json:"meanings"`
+}
+
 func main() {
+	opts, err := parseFlags(os.Args[1:], os.Stderr)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
 	d := cedict.New()
-	s := strings.Join(os.Args[1:], " ")
+	if err := d.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if opts.repl {
+		err = repl(d, opts, os.Stdin, os.Stdout)
+	} else {
+		err = run(d, opts, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseFlags parses command line arguments, the remaining
+// arguments are joined to form the query.
+func parseFlags(args []string, output io.Writer) (*options, error) {
+	opts := &options{}
+	fs := flag.NewFlagSet("cedict", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintf(output, "usage: cedict [flags] <hanzi|pinyin|english>\n")
+		fmt.Fprintf(output, "       cedict -i [flags]\n")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&opts.json, "json", false, "print matching entries as JSON")
+	fs.BoolVar(&opts.repl, "i", false, "interactive mode, read queries from stdin")
+	fs.BoolVar(&opts.trad, "trad", false, "only show traditional hanzi")
+	fs.BoolVar(&opts.simp, "simp", false, "only show simplified hanzi")
+	fs.BoolVar(&opts.tones, "tones", false, "show pinyin with tone marks (default)")
+	fs.BoolVar(&opts.numbers, "numbers", false, "show pinyin with tone numbers")
+	fs.IntVar(&opts.limit, "limit", cedict.MaxResults, "maximum number of entries shown")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// validate flag combinations
+	if opts.trad && opts.simp {
+		return nil, errors.New("cannot use -trad with -simp")
+	}
+	if opts.tones && opts.numbers {
+		return nil, errors.New("cannot use -tones with -numbers")
+	}
+	if opts.limit < 0 {
+		return nil, errors.New("-limit must not be negative")
+	}
+
+	// tone marks are shown unless tone numbers are requested
+	if !opts.tones && !opts.numbers {
+		opts.tones = true
+	}
+
+	opts.query = strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if opts.query == "" && !opts.repl {
+		fs.Usage()
+		return nil, errors.New("expected hanzi, pinyin or english input")
+	}
+
+	return opts, nil
+}
 
-	if cedict.IsHanzi(s) {
-		fmt.Printf("[input] hanzi\n")
+// run executes the query and writes the results.
+func run(d *cedict.Dict, opts *options, w io.Writer) error {
+	kind, entries := detect(d, opts.query)
+	switch kind {
+	case inputHanzi:
 
 		// convert to pinyin
-		fmt.Printf("%s\n", cedict.PinyinTones(d.HanziToPinyin(s)))
+		if !opts.json {
+			p := d.HanziToPinyin(opts.query)
+			if opts.tones {
+				p = cedict.FixSymbolSpaces(cedict.PinyinTones(p))
+			}
+			_, err := fmt.Fprintf(w, "%s\n", p)
+			return err
+		}
 
-	} else {
-		fmt.Printf("[input] english \n")
+		// lookup each word
+		for _, s := range d.Segment(opts.query) {
+			if e := d.GetByHanzi(s); e != nil {
+				entries = append(entries, e)
+			}
+		}
+
+	case inputEnglish:
 
 		// search by meaning
-		elements := d.GetByMeaning(s)
-		for _, e := range elements {
-			fmt.Printf("%s\n", e.Marshal())
+		entries = d.GetByMeaning(opts.query)
+	}
+
+	// limit results shown
+	if len(entries) > opts.limit {
+		entries = entries[:opts.limit]
+	}
+
+	if opts.json {
+		return writeJSON(w, entries, opts)
+	}
+
+	color := cedict.IsTerminal(w)
+	for _, e := range entries {
+		if opts.numbers {
+			if _, err := fmt.Fprintf(w, "%s\n", format(e, opts).Marshal()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := cedict.WriteEntry(w, format(e, opts), color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repl reads queries line by line, writing the results of each until EOF.
+// A prompt is shown if the output is a terminal.
+func repl(d *cedict.Dict, opts *options, r io.Reader, w io.Writer) error {
+	prompt := func() {
+		if cedict.IsTerminal(w) {
+			fmt.Fprint(w, "> ")
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for prompt(); scanner.Scan(); prompt() {
+		q := *opts
+		q.query = strings.TrimSpace(scanner.Text())
+		if q.query == "" {
+			continue
 		}
+		if err := run(d, &q, w); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// input kinds returned by detect.
+const (
+	inputEnglish = iota
+	inputHanzi
+	inputHeadword
+	inputPinyin
+)
+
+// detect returns the kind of input in the query, with the entries found
+// for headwords and pinyin. Input made up of valid pinyin syllables is
+// only treated as pinyin if it matches an entry, as english words such
+// as "he" are also valid syllables. Headwords which aren't all hanzi,
+// such as 卡拉OK, are looked up rather than converted.
+func detect(d *cedict.Dict, query string) (int, []*cedict.Entry) {
+	if cedict.IsHanzi(query) {
+		return inputHanzi, nil
+	}
+
+	// lookup entries with latin letters
+	if entries := d.GetAllByHanzi(query); len(entries) > 0 {
+		return inputHeadword, entries
+	}
+
+	// search by pinyin
+	if cedict.IsPinyin(query) {
+		if entries := d.GetByPinyin(query); len(entries) > 0 {
+			return inputPinyin, entries
+		}
+	}
+	return inputEnglish, nil
+}
+
+// writeJSON writes the entries as a JSON array.
+func writeJSON(w io.Writer, entries []*cedict.Entry, opts *options) error {
+	results := make([]result, 0, len(entries))
+	for _, e := range entries {
+		e = format(e, opts)
+		r := result{Pinyin: e.Pinyin, Meanings: e.Meanings}
+		if !opts.simp {
+			r.Traditional = e.Traditional
+		}
+		if !opts.trad {
+			r.Simplified = e.Simplified
+		}
+		results = append(results, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// format returns a copy of the entry, using the hanzi
+// script and pinyin style selected by the options.
+func format(e *cedict.Entry, opts *options) *cedict.Entry {
+	f := *e
+	if opts.trad {
+		f.Simplified = f.Traditional
+	}
+	if opts.simp {
+		f.Traditional = f.Simplified
+	}
+	if opts.tones {
+		f.Pinyin = cedict.PinyinTones(f.Pinyin)
 	}
+	return &f
 }
@@ -0,0 +1,162 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/jcramb/cedict"
+)
+
+func TestParseFlags(t *testing.T) {
+	opts, err := parseFlags([]string{"-json", "-simp", "-numbers", "-limit", "5", "中", "文"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.json || !opts.simp || opts.trad || opts.tones || !opts.numbers {
+		t.Errorf("unexpected flags %+v", opts)
+	}
+	if opts.limit != 5 || opts.query != "中 文" {
+		t.Errorf("got limit %d, query '%s' (want 5, '中 文')", opts.limit, opts.query)
+	}
+
+	opts, err = parseFlags([]string{"chinese", "language"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.json || !opts.tones || opts.limit != cedict.MaxResults || opts.query != "chinese language" {
+		t.Errorf("unexpected defaults %+v", opts)
+	}
+
+	opts, err = parseFlags([]string{"-tones", "中文"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.tones || opts.numbers {
+		t.Errorf("unexpected flags %+v", opts)
+	}
+
+	invalid := map[string][]string{
+		"-trad with -simp":     {"-trad", "-simp", "中文"},
+		"-tones with -numbers": {"-tones", "-numbers", "中文"},
+		"must not be negative": {"-limit", "-1", "中文"},
+		"expected hanzi":       {"-json"},
+		"not defined":          {"-unknown", "中文"},
+	}
+	for wantErr, args := range invalid {
+		_, err := parseFlags(args, ioutil.Discard)
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: got '%v', want '%s'", args, err, wantErr)
+		}
+	}
+}
+
+// parseTestDict returns a small offline Dict for testing.
+func parseTestDict(t *testing.T) *cedict.Dict {
+	t.Helper()
+	d, err := cedict.ParseString("#! entries=4\n" +
+		"中文 中文 [Zhong1 wen2] /Chinese language/\n" +
+		"美國人 美国人 [Mei3 guo2 ren2] /American/\n" +
+		"人 人 [ren2] /person/\n" +
+		"卡拉OK 卡拉OK [ka3 la1 O K] /karaoke/\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestRun(t *testing.T) {
+	d := parseTestDict(t)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-tones", "中文"}, "Zhōngwén\n"},
+		{[]string{"中文"}, "Zhōngwén\n"},
+		{[]string{"-numbers", "中文"}, "Zhong1wen2\n"},
+		{[]string{"-simp", "american"}, "美国人 [Měi guó rén]\n  1. American\n"},
+		{[]string{"-numbers", "american"}, "美國人 美国人 [Mei3 guo2 ren2] /American/\n"},
+		{[]string{"-numbers", "-simp", "american"}, "美国人 美国人 [Mei3 guo2 ren2] /American/\n"},
+		{[]string{"-numbers", "-trad", "mei3 guo2 ren2"}, "美國人 美國人 [Mei3 guo2 ren2] /American/\n"},
+		{[]string{"-limit", "0", "american"}, ""},
+		{[]string{"zhongwen"}, "中文 [Zhōng wén]\n  1. Chinese language\n"},
+		{[]string{"-numbers", "mei3 guo2 ren2"}, "美國人 美国人 [Mei3 guo2 ren2] /American/\n"},
+		{[]string{"卡拉ok"}, "卡拉OK [kǎ lā O K]\n  1. karaoke\n"},
+	}
+	for _, test := range tests {
+		opts, err := parseFlags(test.args, ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := run(d, opts, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q:\ngot:  %q\nwant: %q", test.args, got, test.want)
+		}
+	}
+
+	// pinyin input without matches falls back to english
+	kinds := map[string]int{
+		"中文":         inputHanzi,
+		"zhongwen":   inputPinyin,
+		"Zhōng wén":  inputPinyin,
+		"american":   inputEnglish,
+		"ren":        inputPinyin,
+		"an":         inputEnglish,
+		"person zzz": inputEnglish,
+		"卡拉ok":       inputHeadword,
+		"卡拉OK":       inputHeadword,
+	}
+	for in, want := range kinds {
+		if got, _ := detect(d, in); got != want {
+			t.Errorf("detect(%q) got %d (want %d)", in, got, want)
+		}
+	}
+
+	// json output
+	opts, err := parseFlags([]string{"-json", "-trad", "美国人"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := run(d, opts, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var results []result
+	if err := json.Unmarshal(buf.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Traditional != "美國人" || results[0].Simplified != "" ||
+		results[0].Pinyin != "Měi guó rén" {
+		t.Errorf("unexpected json results %+v", results)
+	}
+}
+
+func TestRepl(t *testing.T) {
+	d := parseTestDict(t)
+	opts, err := parseFlags([]string{"-i", "-numbers"}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := strings.NewReader("中文\n\nzhongwen\n  american  \n")
+	var buf bytes.Buffer
+	if err := repl(d, opts, in, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "Zhong1wen2\n" +
+		"中文 中文 [Zhong1 wen2] /Chinese language/\n" +
+		"美國人 美国人 [Mei3 guo2 ren2] /American/\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:  %q\nwant: %q", got, want)
+	}
+}
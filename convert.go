@@ -0,0 +1,191 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// convertChunkSize is the length in runes after which long lines are
+// converted in pieces by ConvertReader.
+const convertChunkSize = 64 * 1024
+
+// ConvertReader reads hanzi text and writes pinyin with tone marks, as per
+// HanziToPinyinWith, converting one line at a time. Lines longer than 64K
+// runes are converted in pieces, split between words, so long lines of
+// any text are streamed rather than held in memory. Leading whitespace
+// and line endings, including "\r\n", are kept.
+func (d *Dict) ConvertReader(r io.Reader, w io.Writer) error {
+	return d.snapshot().convertReader(r, w, convertChunkSize)
+}
+
+// convertReader implements ConvertReader, converting lines in pieces
+// of at least chunkSize runes.
+func (d *Dict) convertReader(r io.Reader, w io.Writer, chunkSize int) error {
+	br := bufio.NewReader(r)
+	c := &lineConverter{d: d, w: bufio.NewWriter(w), opts: ConvertOptions{Tones: true}}
+
+	for {
+		ch, _, err := br.ReadRune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.WithStack(err)
+		}
+
+		// keep windows line endings
+		ending := ""
+		switch ch {
+		case '\n':
+			ending = "\n"
+		case '\r':
+			next, _, err := br.ReadRune()
+			if err == nil && next == '\n' {
+				ending = "\r\n"
+			} else if err == nil {
+				br.UnreadRune()
+			} else if err != io.EOF {
+				return errors.WithStack(err)
+			}
+		}
+		if ending != "" {
+			if err := c.endLine(ending); err != nil {
+				return errors.WithStack(err)
+			}
+			continue
+		}
+
+		// keep leading whitespace
+		if !c.started && unicode.IsSpace(ch) {
+			c.write(string(ch))
+			continue
+		}
+		c.started = true
+
+		c.buf = append(c.buf, []rune(ConvertSymbols(string(ch)))...)
+		if len(c.buf) >= chunkSize {
+			if err := c.convert(false); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	if err := c.convert(true); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(c.w.Flush())
+}
+
+// lineConverter converts a line to pinyin in pieces, giving the same
+// output as converting the whole line with pinyinWords.
+type lineConverter struct {
+	d    *Dict
+	w    *bufio.Writer
+	opts ConvertOptions
+	err  error
+
+	buf     []rune // text not yet converted
+	started bool   // leading whitespace has been written
+	written bool   // pinyin has been written, so isn't capitalized
+	space   string // trailing whitespace, written if more pinyin follows
+	skip    bool   // whitespace following a word is skipped
+	run     bool   // non-hanzi were written, needing a trailing space
+}
+
+// convert writes the pinyin of the buffered text. Unless final, hanzi
+// are only matched if the longest possible word is buffered, and the
+// rest is kept, so words aren't split between pieces.
+func (c *lineConverter) convert(final bool) error {
+	runes, i := c.buf, 0
+	maxLen := c.d.maxWordLen()
+	for i < len(runes) {
+
+		// skip whitespace following a word
+		if c.skip && unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		c.skip = false
+
+		// non-hanzi are written as they are read, with a space once
+		// followed by hanzi or the line ends
+		if !unicode.In(runes[i], unicode.Han) {
+			j := i + 1
+			for ; j < len(runes) && !unicode.In(runes[j], unicode.Han); j++ {
+			}
+			c.write(strings.ToLower(string(runes[i:j])))
+			c.run = true
+			i = j
+			continue
+		}
+		if c.run {
+			c.write(" ")
+			c.run = false
+		}
+
+		// match the longest hanzi combo
+		if !final && len(runes)-i < maxLen {
+			break
+		}
+		n, e := c.d.matchWord(runes[i:])
+		if e == nil {
+			c.write(segmentPinyin(runes[i:i+1], nil, c.opts))
+			i++
+			continue
+		}
+		c.write(segmentPinyin(runes[i:i+n], e, c.opts))
+		c.skip = true
+		i += n
+	}
+	c.buf = append(c.buf[:0], runes[i:]...)
+	return c.err
+}
+
+// endLine converts the rest of the line and writes the line ending.
+func (c *lineConverter) endLine(ending string) error {
+	err := c.convert(true)
+	if _, werr := c.w.WriteString(ending); err == nil {
+		err = werr
+	}
+	c.buf = c.buf[:0]
+	c.started, c.written, c.skip, c.run = false, false, false, false
+	c.space = ""
+	return err
+}
+
+// write writes the text, capitalizing the first pinyin of the line and
+// holding back trailing whitespace, which is dropped at the line end.
+func (c *lineConverter) write(s string) {
+	if !c.started {
+		c.writeString(s)
+		return
+	}
+	text := strings.TrimRightFunc(s, unicode.IsSpace)
+	if text == "" {
+		c.space += s
+		return
+	}
+	if !c.written {
+		r, n := utf8.DecodeRuneInString(text)
+		text = string(unicode.ToUpper(r)) + text[n:]
+		c.written = true
+		c.space = ""
+	}
+	c.writeString(c.space + text)
+	c.space = s[len(strings.TrimRightFunc(s, unicode.IsSpace)):]
+}
+
+// writeString writes to the output, keeping the first error.
+func (c *lineConverter) writeString(s string) {
+	if _, err := c.w.WriteString(s); err != nil && c.err == nil {
+		c.err = err
+	}
+}
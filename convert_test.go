@@ -0,0 +1,130 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestConvertReader(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	in := "我们的大学\n\n你好, 中国人!\r\n学生abc 银行\n"
+	want := "Wǒ men de dà xué\n\nNǐ hǎo ,  Zhōngguórén !\r\nXué sheng abc  yín háng\n"
+
+	// reading a byte at a time splits multi-byte runes between reads
+	for _, r := range []io.Reader{strings.NewReader(in), iotest.OneByteReader(strings.NewReader(in))} {
+		var buf bytes.Buffer
+		if err := d.ConvertReader(r, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("got:\n%q\nwant:\n%q", buf.String(), want)
+		}
+	}
+
+	// each line is converted as per HanziToPinyinWith
+	for _, line := range strings.Split(strings.ReplaceAll(in, "\r", ""), "\n") {
+		if !strings.Contains(want, d.HanziToPinyinWith(line, ConvertOptions{Tones: true})) {
+			t.Errorf("'%s' - not converted as per HanziToPinyinWith", line)
+		}
+	}
+
+	// no trailing line break
+	var buf bytes.Buffer
+	if err := d.ConvertReader(strings.NewReader("中文"), &buf); err != nil || buf.String() != "Zhōngwén" {
+		t.Errorf("got '%s', %v (want 'Zhōngwén')", buf.String(), err)
+	}
+}
+
+func TestConvertReaderChunks(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	// long lines split into pieces match converting the whole line
+	line := strings.Repeat("中国人民银行, 我们的大学。", 50)
+	in := line + "\n" + line
+	want, _ := d.hanziToPinyin(line, ConvertOptions{Tones: true})
+	want += "\n" + want
+	for _, size := range []int{1, 2, 7, 64, 1000} {
+		var buf bytes.Buffer
+		if err := d.convertReader(strings.NewReader(in), &buf, size); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("%d - got:\n%s\nwant:\n%s", size, buf.String(), want)
+		}
+	}
+}
+
+// readerFunc is an io.Reader calling the function.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestConvertReaderStream(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	// long lines of only hanzi or only latin letters are converted in
+	// pieces, written before the end of the input is read
+	for _, line := range []string{
+		strings.Repeat("中国人民银行我们的大学", 10000),
+		strings.Repeat("abcdefghij", 20000),
+	} {
+		var buf bytes.Buffer
+		written := -1
+		r := io.MultiReader(strings.NewReader(line), readerFunc(func(p []byte) (int, error) {
+			written = buf.Len()
+			return 0, io.EOF
+		}))
+		if err := d.ConvertReader(r, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if written <= 0 {
+			t.Errorf("got %d bytes written before the end of input (want some)", written)
+		}
+		if want, _ := d.hanziToPinyin(line, ConvertOptions{Tones: true}); buf.String() != want {
+			t.Errorf("got %d bytes (want %d bytes as per HanziToPinyinWith)", buf.Len(), len(want))
+		}
+	}
+
+	// pieces of mixed text match converting the whole line
+	line := strings.Repeat("中国人民银行 abc  DEF 我们的大学学生abc 银行 , 中文。", 20)
+	want, _ := d.hanziToPinyin(line, ConvertOptions{Tones: true})
+	for _, size := range []int{1, 2, 3, 7, 64, 1000} {
+		var buf bytes.Buffer
+		if err := d.convertReader(strings.NewReader(line), &buf, size); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("%d - got:\n%s\nwant:\n%s", size, buf.String(), want)
+		}
+	}
+
+	// leading whitespace is kept
+	var buf bytes.Buffer
+	if err := d.ConvertReader(strings.NewReader("  中文\n\t学生 abc"), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "  Zhōngwén\n\tXué sheng abc"; buf.String() != want {
+		t.Errorf("got %q (want %q)", buf.String(), want)
+	}
+}
+
+func TestConvertReaderError(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	want := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("中文\n"), iotest.ErrReader(want))
+	var buf bytes.Buffer
+	if err := d.ConvertReader(r, &buf); !errors.Is(err, want) {
+		t.Errorf("got %v (want %v)", err, want)
+	}
+}
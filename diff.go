@@ -0,0 +1,90 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+// DictDiff represents the changes between two versions of a Dict.
+// Entries are matched using their traditional hanzi and pinyin.
+type DictDiff struct {
+	Added    []*Entry
+	Removed  []*Entry
+	Modified []EntryChange
+}
+
+// EntryChange represents an entry with changed simplified
+// hanzi or meanings between two versions of a Dict.
+type EntryChange struct {
+	Old *Entry
+	New *Entry
+}
+
+// diffKey returns the key used to match entries between versions.
+func diffKey(e *Entry) string {
+	return e.Traditional + " [" + e.Pinyin + "]"
+}
+
+// Diff returns the entries added, removed and modified in the next Dict
+// compared to the old Dict. Added and modified entries are in the order
+// of the next Dict, and removed entries in the order of the old Dict.
+// Entries sharing the same key, i.e. with different meanings for the
+// same reading, are matched to identical entries first, then in order.
+func Diff(old, next *Dict) DictDiff {
+	old = old.snapshot()
+	next = next.snapshot()
+
+	// group entries of both versions by key
+	prev := make(map[string][]*Entry, len(old.e))
+	for _, e := range old.e {
+		prev[diffKey(e)] = append(prev[diffKey(e)], e)
+	}
+	groups := make(map[string][]*Entry, len(next.e))
+	for _, e := range next.e {
+		groups[diffKey(e)] = append(groups[diffKey(e)], e)
+	}
+
+	// pair each next entry with an old entry of the same key,
+	// preferring an identical entry, otherwise the next unpaired one
+	pairs := make(map[*Entry]*Entry)
+	paired := make(map[*Entry]bool)
+	for k, group := range groups {
+		var rest []*Entry
+		for _, e := range group {
+			for _, o := range prev[k] {
+				if !paired[o] && o.Marshal() == e.Marshal() {
+					pairs[e], paired[o] = o, true
+					break
+				}
+			}
+			if pairs[e] == nil {
+				rest = append(rest, e)
+			}
+		}
+		for _, o := range prev[k] {
+			if len(rest) == 0 {
+				break
+			}
+			if !paired[o] {
+				pairs[rest[0]], paired[o] = o, true
+				rest = rest[1:]
+			}
+		}
+	}
+
+	var diff DictDiff
+	for _, e := range next.e {
+		o, ok := pairs[e]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, e)
+		case o.Marshal() != e.Marshal():
+			diff.Modified = append(diff.Modified, EntryChange{Old: o, New: e})
+		}
+	}
+	for _, e := range old.e {
+		if !paired[e] {
+			diff.Removed = append(diff.Removed, e)
+		}
+	}
+	return diff
+}
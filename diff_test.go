@@ -0,0 +1,70 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := parseTestDict(t,
+		"中 中 [zhong1] /within/among/",
+		"中國 中国 [Zhong1 guo2] /China/",
+		"人 人 [ren2] /person/",
+		"們 们 [men5] /plural marker/",
+	)
+	next := parseTestDict(t,
+		"中 中 [zhong1] /within/among/",
+		"中國 中国 [Zhong1 guo2] /China/Middle Kingdom/",
+		"人 人 [ren2] /person/",
+		"中文 中文 [Zhong1 wen2] /Chinese language/",
+	)
+
+	diff := Diff(old, next)
+	if len(diff.Added) != 1 || diff.Added[0].Traditional != "中文" {
+		t.Errorf("got added %v (want 中文)", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Traditional != "們" {
+		t.Errorf("got removed %v (want 們)", diff.Removed)
+	}
+	if len(diff.Modified) != 1 {
+		t.Fatalf("got %d modified (want 1)", len(diff.Modified))
+	}
+	m := diff.Modified[0]
+	if m.Old.Traditional != "中國" || len(m.Old.Meanings) != 1 || len(m.New.Meanings) != 2 {
+		t.Errorf("got modified %s -> %s", m.Old.Marshal(), m.New.Marshal())
+	}
+
+	// entries sharing a key are paired with identical entries, then in order
+	old = parseTestDict(t,
+		"行 行 [xing2] /to walk/",
+		"行 行 [xing2] /capable/",
+		"行 行 [xing2] /OK/",
+		"行 行 [hang2] /row/",
+	)
+	next = parseTestDict(t,
+		"行 行 [xing2] /capable/competent/",
+		"行 行 [xing2] /OK/",
+		"行 行 [hang2] /row/",
+		"行 行 [hang2] /profession/",
+	)
+	diff = Diff(old, next)
+	if len(diff.Added) != 1 || diff.Added[0].Meanings[0] != "profession" {
+		t.Errorf("got added %v (want profession)", diff.Added)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0].Old.Meanings[0] != "to walk" ||
+		diff.Modified[0].New.Meanings[0] != "capable" {
+		t.Errorf("got modified %v (want to walk -> capable/competent)", diff.Modified)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Meanings[0] != "capable" {
+		t.Errorf("got removed %v (want capable)", diff.Removed)
+	}
+
+	// identical dicts have no changes
+	diff = Diff(old, old)
+	if len(diff.Added)+len(diff.Removed)+len(diff.Modified) != 0 {
+		t.Errorf("got %+v (want no changes)", diff)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ANSI escape codes used by WriteEntry for colored output.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiHanzi   = "\x1b[1;33m"
+	ansiPinyin  = "\x1b[36m"
+	ansiMeaning = "\x1b[37m"
+)
+
+// WriteEntry writes the entry for display in a terminal, with hanzi and
+// pinyin on the first line followed by a numbered list of meanings.
+// ANSI colors are used if color is true, see IsTerminal.
+func WriteEntry(w io.Writer, e *Entry, color bool) error {
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	hanzi := e.Traditional
+	if e.Traditional != e.Simplified {
+		hanzi += "|" + e.Simplified
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s [%s]\n", paint(ansiHanzi, hanzi), paint(ansiPinyin, PinyinTones(e.Pinyin)))
+	for i, m := range e.Meanings {
+		fmt.Fprintf(&sb, "  %d. %s\n", i+1, paint(ansiMeaning, m))
+	}
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// IsTerminal returns true if the writer is a terminal (character device),
+// which can be used to decide whether to write colored output.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// WriteMarkdown writes the entries as a Markdown table, with columns for
+// hanzi, pinyin with tones and meanings. Pipes in cells are escaped.
+func (d *Dict) WriteMarkdown(w io.Writer, entries []*Entry) error {
+	if _, err := io.WriteString(w, "| Hanzi | Pinyin | Meanings |\n| --- | --- | --- |\n"); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, e := range entries {
+		hanzi := e.Simplified
+		if e.Traditional != e.Simplified {
+			hanzi = fmt.Sprintf("%s (%s)", e.Simplified, e.Traditional)
+		}
+		line := fmt.Sprintf("| %s | %s | %s |\n",
+			escapeMarkdown(hanzi),
+			escapeMarkdown(PinyinTones(e.Pinyin)),
+			escapeMarkdown(strings.Join(e.Meanings, "; ")))
+		if _, err := io.WriteString(w, line); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// escapeMarkdown escapes characters which would break a Markdown table cell.
+func escapeMarkdown(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+// String returns the entry in a readable format, i.e.
+// "中文 (Zhōng wén): Chinese language; Chinese writing".
+// Differing hanzi are shown in CC-CEDICT style, i.e. "個|个".
+func (e *Entry) String() string {
+	hanzi := e.Traditional
+	if e.Traditional != e.Simplified {
+		hanzi += "|" + e.Simplified
+	}
+	return fmt.Sprintf("%s (%s): %s", hanzi, PinyinTones(e.Pinyin),
+		strings.Join(e.Meanings, "; "))
+}
+
+// RubyBoth returns the entry's traditional and simplified hanzi as HTML
+// ruby annotations, with the pinyin in tone marks above each character,
+// i.e. "<ruby>中<rt>Zhōng</rt>文<rt>wén</rt></ruby>". If the number of
+// characters doesn't match the number of syllables, the pinyin annotates
+// the whole word instead.
+func (e *Entry) RubyBoth() (tradHTML, simpHTML string) {
+	return rubyHTML(e.Traditional, e.Pinyin), rubyHTML(e.Simplified, e.Pinyin)
+}
+
+// rubyHTML returns the hanzi annotated with the CC-CEDICT formatted pinyin,
+// one syllable per character if the counts match, or as a whole otherwise.
+func rubyHTML(hanzi, pinyin string) string {
+	chars, ok := alignPinyin(hanzi, pinyin)
+	if !ok {
+		return fmt.Sprintf("<ruby>%s<rt>%s</rt></ruby>",
+			html.EscapeString(hanzi), html.EscapeString(PinyinTones(pinyin)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<ruby>")
+	for _, c := range chars {
+		fmt.Fprintf(&sb, "%s<rt>%s</rt>",
+			html.EscapeString(c.Char), html.EscapeString(PinyinTones(c.Pinyin)))
+	}
+	sb.WriteString("</ruby>")
+	return sb.String()
+}
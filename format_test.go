@@ -0,0 +1,127 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	entries := []*Entry{
+		d.GetByHanzi("中文"),
+		d.GetByHanzi("美國人"),
+	}
+
+	var buf bytes.Buffer
+	if err := d.WriteMarkdown(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "| Hanzi | Pinyin | Meanings |\n" +
+		"| --- | --- | --- |\n" +
+		"| 中文 | Zhōng wén | Chinese language |\n" +
+		"| 美国人 (美國人) | Měi guó rén | American; American person; American people; CL:個\\|个[ge4] |\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEntryString(t *testing.T) {
+	tests := map[string]string{
+		"中文 中文 [Zhong1 wen2] /Chinese language/Chinese writing/": "中文 (Zhōng wén): Chinese language; Chinese writing",
+		"美國人 美国人 [Mei3 guo2 ren2] /American/American person/":    "美國人|美国人 (Měi guó rén): American; American person",
+	}
+	for s, want := range tests {
+		e := &Entry{}
+		if err := e.Unmarshal(s); err != nil {
+			t.Fatal(err)
+		}
+		if got := e.String(); got != want {
+			t.Errorf("got '%s' (want '%s')", got, want)
+		}
+		if got := fmt.Sprint(e); got != want {
+			t.Errorf("fmt.Stringer got '%s' (want '%s')", got, want)
+		}
+	}
+}
+
+func TestWriteEntry(t *testing.T) {
+	e := &Entry{}
+	if err := e.Unmarshal("中文 中文 [Zhong1 wen2] /Chinese language/Chinese writing/"); err != nil {
+		t.Fatal(err)
+	}
+
+	// buffers and files are not terminals
+	var buf bytes.Buffer
+	if IsTerminal(&buf) {
+		t.Errorf("bytes.Buffer is not a terminal")
+	}
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "entry.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if IsTerminal(f) {
+		t.Errorf("%s is not a terminal", f.Name())
+	}
+
+	if err := WriteEntry(&buf, e, IsTerminal(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	want := "中文 [Zhōng wén]\n  1. Chinese language\n  2. Chinese writing\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := WriteEntry(&buf, e, true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ansiHanzi+"中文"+ansiReset) {
+		t.Errorf("expected colored output, got %q", buf.String())
+	}
+}
+
+func TestRubyBoth(t *testing.T) {
+	e := &Entry{}
+	if err := e.Unmarshal("美國人 美国人 [Mei3 guo2 ren2] /American/American person/"); err != nil {
+		t.Fatal(err)
+	}
+	trad, simp := e.RubyBoth()
+	if want := "<ruby>美<rt>Měi</rt>國<rt>guó</rt>人<rt>rén</rt></ruby>"; trad != want {
+		t.Errorf("got '%s' (want '%s')", trad, want)
+	}
+	if want := "<ruby>美<rt>Měi</rt>国<rt>guó</rt>人<rt>rén</rt></ruby>"; simp != want {
+		t.Errorf("got '%s' (want '%s')", simp, want)
+	}
+
+	// mismatched character counts annotate the whole word
+	e = &Entry{Traditional: "卡拉OK", Simplified: "卡拉OK", Pinyin: "ka3 la1 O K"}
+	trad, simp = e.RubyBoth()
+	if want := "<ruby>卡<rt>kǎ</rt>拉<rt>lā</rt>O<rt>O</rt>K<rt>K</rt></ruby>"; trad != want || simp != want {
+		t.Errorf("got '%s', '%s' (want '%s')", trad, simp, want)
+	}
+	e = &Entry{Traditional: "麼麼", Simplified: "么", Pinyin: "me5"}
+	trad, simp = e.RubyBoth()
+	if want := "<ruby>麼麼<rt>me</rt></ruby>"; trad != want {
+		t.Errorf("got '%s' (want '%s')", trad, want)
+	}
+	if want := "<ruby>么<rt>me</rt></ruby>"; simp != want {
+		t.Errorf("got '%s' (want '%s')", simp, want)
+	}
+
+	// html is escaped
+	e = &Entry{Traditional: "<>", Simplified: "<>", Pinyin: "a1"}
+	if trad, _ := e.RubyBoth(); trad != "<ruby>&lt;&gt;<rt>ā</rt></ruby>" {
+		t.Errorf("got '%s'", trad)
+	}
+}
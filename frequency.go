@@ -0,0 +1,40 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+// commonReadings maps common heteronyms, characters with multiple
+// readings, to their most frequent reading in modern written chinese.
+// HanziToPinyin uses these for characters not part of a longer word,
+// instead of the first reading in the CC-CEDICT file order.
+var commonReadings = map[string]string{
+	"重": "zhong4", "行": "xing2", "长": "chang2", "長": "chang2",
+	"了": "le5", "得": "de5", "着": "zhe5", "著": "zhe5",
+	"还": "hai2", "還": "hai2", "都": "dou1", "只": "zhi3",
+	"会": "hui4", "會": "hui4", "为": "wei4", "為": "wei4",
+	"和": "he2", "觉": "jue2", "覺": "jue2", "种": "zhong3",
+	"種": "zhong3", "乐": "le4", "樂": "le4", "好": "hao3",
+	"要": "yao4", "相": "xiang1", "便": "bian4", "数": "shu4",
+	"數": "shu4", "少": "shao3", "发": "fa1", "發": "fa1",
+	"处": "chu4", "處": "chu4", "调": "diao4", "調": "diao4",
+	"中": "zhong1", "看": "kan4", "差": "cha4", "空": "kong1",
+	"当": "dang1", "當": "dang1", "的": "de5", "大": "da4",
+	"没": "mei2", "沒": "mei2", "过": "guo4", "過": "guo4",
+	"给": "gei3", "給": "gei3", "弹": "tan2", "彈": "tan2",
+	"传": "chuan2", "傳": "chuan2", "分": "fen1", "更": "geng4",
+	"教": "jiao4", "藏": "cang2", "降": "jiang4", "假": "jia3",
+	"间": "jian1", "間": "jian1", "量": "liang4", "难": "nan2",
+	"難": "nan2", "强": "qiang2", "強": "qiang2", "切": "qie1",
+	"省": "sheng3", "思": "si1", "似": "si4", "系": "xi4",
+	"应": "ying1", "應": "ying1", "乘": "cheng2", "称": "cheng1",
+	"稱": "cheng1", "冲": "chong1", "衝": "chong1", "答": "da2",
+	"倒": "dao4", "度": "du4", "参": "can1", "參": "can1",
+	"曾": "ceng2", "几": "ji3", "幾": "ji3", "将": "jiang1",
+	"將": "jiang1", "卷": "juan3", "率": "lu:4", "模": "mo2",
+	"那": "na4", "屏": "ping2", "曲": "qu3", "散": "san4",
+	"上": "shang4", "盛": "sheng4", "识": "shi2", "識": "shi2",
+	"属": "shu3", "屬": "shu3", "提": "ti2", "校": "xiao4",
+	"一": "yi1", "正": "zheng4", "转": "zhuan3", "轉": "zhuan3",
+	"作": "zuo4", "哪": "na3", "吗": "ma5", "嗎": "ma5",
+}
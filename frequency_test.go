@@ -0,0 +1,71 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"testing"
+)
+
+func TestCommonReadings(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"重 重 [chong2] /to repeat/repetition/again/",
+		"重 重 [zhong4] /heavy/serious/to attach importance to/",
+		"重慶 重庆 [Chong2 qing4] /Chongqing city/",
+		"長 长 [zhang3] /chief/head/to grow/",
+		"長 长 [chang2] /length/long/",
+		"率 率 [shuai4] /to lead/to command/",
+		"率 率 [lu:4] /rate/frequency/",
+	)...)
+
+	tests := map[string]string{
+		"重":   "Zhong4",
+		"重慶":  "Chong2qing4",
+		"长":   "Chang2",
+		"長":   "Chang2",
+		"一行人": "Yi1 xing2 ren2",
+		"率":   "Lu:4",
+	}
+	for s, want := range tests {
+		if got := d.HanziToPinyin(s); got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", s, got, want)
+		}
+	}
+
+	// ü readings use the u: spelling of the entries
+	if got := PinyinTones(d.HanziToPinyin("率")); got != "Lǜ" {
+		t.Errorf("got '%s' (want 'Lǜ')", got)
+	}
+
+	// lookups still return the first entry in file order
+	if e := d.GetByHanzi("重"); e == nil || e.Pinyin != "chong2" {
+		t.Errorf("GetByHanzi - got %v (want chong2)", e)
+	}
+}
+
+func TestCommonReadingsConversion(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"乾 干 [gan1] /dry/",
+		"幹 干 [gan4] /to do/",
+		"干 干 [gan1] /to concern/shield/",
+		"髮 发 [fa4] /hair/",
+		"發 发 [fa1] /to send out/",
+	)...)
+
+	// contested readings use the first entry
+	if got := d.ToTraditional("干"); got != "乾" && got != "干" {
+		t.Errorf("got '%s' (want '乾' or '干')", got)
+	}
+	if got := d.HanziToPinyin("干"); got != "Gan1" {
+		t.Errorf("got '%s' (want 'Gan1')", got)
+	}
+
+	// preferred readings don't change script conversion
+	if got := d.HanziToPinyin("发"); got != "Fa1" {
+		t.Errorf("got '%s' (want 'Fa1')", got)
+	}
+	if got := d.ToTraditional("发"); got != "髮" {
+		t.Errorf("got '%s' (want '髮')", got)
+	}
+}
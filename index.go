@@ -0,0 +1,241 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"strings"
+	"sync"
+)
+
+// collation is the pinyin collated order of a Dict's entries, which is
+// sorted on first use as only browsing with Neighbors needs it.
+type collation struct {
+	once  sync.Once
+	order []*Entry
+	pos   map[*Entry]int
+}
+
+// sorted returns the collation, sorting the entries if not yet sorted.
+func (c *collation) sorted(entries []*Entry) *collation {
+	c.once.Do(func() {
+		c.order = append([]*Entry(nil), entries...)
+		SortByPinyinCollation(c.order)
+		c.pos = make(map[*Entry]int, len(c.order))
+		for i, e := range c.order {
+			c.pos[e] = i
+		}
+	})
+	return c
+}
+
+// buildIndex populates the Dict's lookup indexes from its entries.
+// It must be called again whenever the entries are modified.
+func (d *Dict) buildIndex() {
+
+	// map traditional and simplified hanzi to entries
+	d.hanzi = make(map[string][]int)
+	d.trie = newTrie()
+	for i, e := range d.e {
+		for _, hanzi := range entryHanzi(e) {
+			d.hanzi[hanzi] = append(d.hanzi[hanzi], i)
+			d.trie.insert(hanzi, i)
+		}
+	}
+
+	// sort entries by pinyin when first browsed
+	d.collation = &collation{}
+
+	// map headwords with latin letters, i.e. 卡拉OK, in lowercase, so they
+	// are looked up in any case, after the exact forms so those are
+	// preferred. these aren't added to the trie, so conversion and
+	// segmentation keep the case of the text
+	d.folded = make(map[string][]int)
+	for i, e := range d.e {
+		for _, hanzi := range entryHanzi(e) {
+			if lower := strings.ToLower(hanzi); lower != hanzi {
+				d.folded[lower] = append(d.folded[lower], i)
+			}
+		}
+	}
+
+	// map characters between scripts, preferring single character entries
+	d.toSimp = make(map[rune]rune)
+	d.toTrad = make(map[rune]rune)
+	for _, single := range []bool{true, false} {
+		for _, e := range d.e {
+			t, s := []rune(e.Traditional), []rune(e.Simplified)
+			if len(t) != len(s) || (len(t) == 1) != single {
+				continue
+			}
+			for i := range t {
+				if t[i] == s[i] {
+					continue
+				}
+				if _, ok := d.toSimp[t[i]]; !ok {
+					d.toSimp[t[i]] = s[i]
+				}
+				if _, ok := d.toTrad[s[i]]; !ok {
+					d.toTrad[s[i]] = t[i]
+				}
+			}
+		}
+	}
+
+	// map each meaning word to the entries containing it,
+	// listing meanings without any words under the empty word
+	d.words = make(map[string][]int)
+	d.wordLen = 0
+	for i, e := range d.e {
+		for _, m := range e.Meanings {
+			words := meaningWords(m)
+			if len(words) == 0 {
+				words = []string{""}
+			}
+			for _, w := range words {
+				ids := d.words[w]
+				if n := len([]rune(w)); len(ids) == 0 && n > d.wordLen {
+					d.wordLen = n
+				}
+				if len(ids) == 0 || ids[len(ids)-1] != i {
+					d.words[w] = append(ids, i)
+				}
+			}
+		}
+	}
+
+	// combine words sharing the same stem
+	d.stems = make(map[string][]int)
+	for w, ids := range d.words {
+		s := stem(w)
+		d.stems[s] = mergeIDs(d.stems[s], ids)
+	}
+}
+
+// lookupIndex returns entries, in dict order, which the index lists
+// under all of the words (if matchAll is true) or any of the words.
+func lookupIndex(entries []*Entry, index map[string][]int, words []string, matchAll bool) []*Entry {
+	var ids []int
+	if matchAll {
+
+		// every word must match, so use the shortest list
+		for i, w := range words {
+			list, ok := index[w]
+			if !ok {
+				return nil
+			}
+			if i == 0 || len(list) < len(ids) {
+				ids = list
+			}
+		}
+	} else {
+		for _, w := range words {
+			ids = mergeIDs(ids, index[w])
+		}
+	}
+
+	results := make([]*Entry, len(ids))
+	for i, id := range ids {
+		results[i] = entries[id]
+	}
+	return results
+}
+
+// mergeIDs returns the union of two sorted lists of unique ids.
+func mergeIDs(a, b []int) []int {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	result := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			result = append(result, a[i])
+			i++
+		case a[i] > b[j]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
+
+// entryHanzi returns the distinct forms of the entry's hanzi, traditional
+// then simplified. Names with middle dots other than the interpunct "·"
+// are also returned with "·", which ConvertSymbols converts them to.
+func entryHanzi(e *Entry) []string {
+	forms := []string{e.Traditional}
+	if e.Simplified != e.Traditional {
+		forms = append(forms, e.Simplified)
+	}
+	for _, f := range forms {
+		if dotted := strings.NewReplacer("・", "·", "･", "·", "‧", "·").Replace(f); dotted != f {
+			forms = append(forms, dotted)
+		}
+	}
+	return forms
+}
+
+// preferredID returns the index of the entry used for the word when
+// converting hanzi to pinyin. This is the most frequent reading for
+// common heteronyms, otherwise the first entry, or -1 if not found.
+func (d *Dict) preferredID(word string) int {
+	ids := d.hanzi[word]
+	if len(ids) == 0 {
+		return -1
+	}
+	if p, ok := commonReadings[word]; ok {
+		for _, id := range ids {
+			if strings.ToLower(d.e[id].Pinyin) == p {
+				return id
+			}
+		}
+	}
+	return ids[0]
+}
+
+// meaningCandidates returns the entries, in dict order, which may have a
+// meaning found in the lowercase input by GetByMeaning. Each word of such
+// a meaning is part of a word in the input, so only entries listed under
+// substrings of the input words are returned, giving the same matches as
+// scanning every entry. Substrings longer than the longest indexed word
+// can't be listed, so the cost grows linearly with the input length.
+func (d *Dict) meaningCandidates(s string) []*Entry {
+	ids := d.words[""]
+	seen := make(map[string]bool)
+	for _, w := range meaningWords(s) {
+
+		// byte offsets of each rune and the end of the word
+		offsets := make([]int, 0, len(w)+1)
+		for i := range w {
+			offsets = append(offsets, i)
+		}
+		offsets = append(offsets, len(w))
+
+		for i := 0; i < len(offsets)-1; i++ {
+			for j := i + 1; j < len(offsets) && j-i <= d.wordLen; j++ {
+				sub := w[offsets[i]:offsets[j]]
+				if !seen[sub] {
+					seen[sub] = true
+					ids = mergeIDs(ids, d.words[sub])
+				}
+			}
+		}
+	}
+
+	results := make([]*Entry, len(ids))
+	for i, id := range ids {
+		results[i] = d.e[id]
+	}
+	return results
+}
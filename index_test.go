@@ -0,0 +1,137 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	d := parseTestDict(t,
+		"紅 红 [hong2] /red/popular/",
+		"花 花 [hua1] /flower/blossom/",
+		"紅花 红花 [hong2 hua1] /safflower/red flower/",
+		"跑 跑 [pao3] /to run/to escape/",
+		"跑步 跑步 [pao3 bu4] /to walk quickly/to march/to run/",
+	)
+
+	tests := []struct {
+		words    []string
+		matchAll bool
+	}{
+		{[]string{"red"}, true},
+		{[]string{"red", "flower"}, true},
+		{[]string{"red", "flower"}, false},
+		{[]string{"red", "missing"}, true},
+		{[]string{"red", "missing"}, false},
+		{[]string{"to", "run"}, false},
+	}
+	for _, test := range tests {
+		indexed := matchMeaningWords(lookupIndex(d.e, d.words, test.words, test.matchAll),
+			test.words, test.matchAll)
+		linear := matchMeaningWords(d.e, test.words, test.matchAll)
+		if !reflect.DeepEqual(indexed, linear) {
+			t.Errorf("%q (%v): indexed results != linear results", test.words, test.matchAll)
+		}
+	}
+
+	if ids := d.stems["run"]; !reflect.DeepEqual(ids, []int{3, 4}) {
+		t.Errorf("stems['run'] got %v (want [3 4])", ids)
+	}
+}
+
+func TestMergeIDs(t *testing.T) {
+	tests := []struct {
+		a, b, want []int
+	}{
+		{nil, nil, nil},
+		{[]int{1, 2}, nil, []int{1, 2}},
+		{nil, []int{1, 2}, []int{1, 2}},
+		{[]int{1, 3, 5}, []int{2, 3, 6}, []int{1, 2, 3, 5, 6}},
+	}
+	for _, test := range tests {
+		if got := mergeIDs(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("mergeIDs(%v, %v) got %v (want %v)", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestMeaningCandidates(t *testing.T) {
+	d := parseTestDict(t,
+		"紅 红 [hong2] /red/popular/",
+		"花 花 [hua1] /flower/blossom/",
+		"紅花 红花 [hong2 hua1] /safflower/red flower/",
+		"跑 跑 [pao3] /to run/to escape/",
+		"跑步 跑步 [pao3 bu4] /to walk quickly/to march/to run/",
+		"去 去 [qu4] /to go/",
+		"好 好 [hao3] /good/go/",
+		"點 点 [dian3] /.../dot/",
+		"三 三 [san1] /3/three/",
+	)
+
+	// candidates give the same matches as scanning every entry
+	queries := []string{
+		"red", "Red Flower", "a red flower", "to run away", "go", "to good",
+		"safflowers", "...", "3 dots", "flow", "", "to", "escape from",
+		"redflowersafflowerblossom", strings.Repeat("torun", 100),
+	}
+	for _, q := range queries {
+		q = strings.ToLower(q)
+		filtered := matchMeanings(d.meaningCandidates(q), q, 1)
+		linear := matchMeanings(d.e, q, 1)
+		if !reflect.DeepEqual(filtered, linear) {
+			t.Errorf("'%s': filtered %v != linear %v", q, filtered, linear)
+		}
+	}
+
+	// entries are skipped without a word in the input,
+	// other than those with meanings without any words
+	var got []string
+	for _, e := range d.meaningCandidates("red flower") {
+		got = append(got, e.Simplified)
+	}
+	if want := []string{"红", "花", "红花", "点"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q (want %q)", got, want)
+	}
+}
+
+func BenchmarkMeaningCandidates(b *testing.B) {
+	d := parseTestDict(b, testEntries...)
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Short", "chinese language"},
+		{"LongSentence", strings.Repeat("the teacher of the chinese language ", 100)},
+		{"LongWord", strings.Repeat("language", 500)},
+	}
+	for _, test := range tests {
+		b.Run(test.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				d.meaningCandidates(test.query)
+			}
+		})
+	}
+}
+
+func BenchmarkMeaningWords(b *testing.B) {
+	d := New()
+	if err := d.Err(); err != nil {
+		b.Skip(err)
+	}
+	query := []string{"water"}
+	b.Run("Indexed", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			matchMeaningWords(lookupIndex(d.e, d.words, query, true), query, true)
+		}
+	})
+	b.Run("Linear", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			matchMeaningWords(d.e, query, true)
+		}
+	})
+}
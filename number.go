@@ -0,0 +1,220 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// hanziDigits are the chinese numerals for 0-9.
+var hanziDigits = []rune("零一二三四五六七八九")
+
+// hanziUnits are the units within a group of four digits.
+var hanziUnits = []string{"", "十", "百", "千"}
+
+// NumberToHanzi returns the number written in chinese numerals,
+// i.e. 123 -> "一百二十三", 10 -> "十", 1001 -> "一千零一". Numbers of
+// 10^12 and above stack the units, i.e. "一万亿" and "一亿亿", as the
+// larger units such as 兆 differ by region.
+func NumberToHanzi(n int64) string {
+	if n == 0 {
+		return string(hanziDigits[0])
+	}
+
+	var b strings.Builder
+	u := uint64(n)
+	if n < 0 {
+		b.WriteString("负")
+		u = uint64(-n)
+	}
+	start := b.Len()
+	writeHanziNumber(&b, u)
+
+	// 10-19 are written without the leading one
+	s := b.String()
+	if strings.HasPrefix(s[start:], "一十") {
+		s = s[:start] + strings.TrimPrefix(s[start:], "一")
+	}
+	return s
+}
+
+// hanziGroupUnits are the units of groups of digits, largest first.
+var hanziGroupUnits = []struct {
+	value uint64
+	unit  string
+}{
+	{1e16, "亿亿"},
+	{1e8, "亿"},
+	{1e4, "万"},
+}
+
+// writeHanziNumber writes a positive number in chinese numerals, writing
+// the multiple of the largest unit followed by the unit, so units stack
+// for large numbers, i.e. 10^12 -> "一万亿", 10^16 -> "一亿亿".
+func writeHanziNumber(b *strings.Builder, u uint64) {
+	for _, g := range hanziGroupUnits {
+		if u < g.value {
+			continue
+		}
+		writeHanziNumber(b, u/g.value)
+		b.WriteString(g.unit)
+
+		// a gap of zeros before the rest is written once
+		if rest := u % g.value; rest > 0 {
+			if rest < g.value/10 {
+				b.WriteRune(hanziDigits[0])
+			}
+			writeHanziNumber(b, rest)
+		}
+		return
+	}
+	writeHanziGroup(b, int(u))
+}
+
+// writeHanziGroup writes a group of up to four digits in chinese numerals.
+func writeHanziGroup(b *strings.Builder, g int) {
+	wrote, zero := false, false
+	for i := 3; i >= 0; i-- {
+		d := g
+		for j := 0; j < i; j++ {
+			d /= 10
+		}
+		d %= 10
+
+		if d == 0 {
+			zero = wrote
+			continue
+		}
+		if zero {
+			b.WriteRune(hanziDigits[0])
+			zero = false
+		}
+		b.WriteRune(hanziDigits[d])
+		b.WriteString(hanziUnits[i])
+		wrote = true
+	}
+}
+
+// HanziToNumber parses a number written in chinese numerals,
+// i.e. "一百二十三" -> 123, "十" -> 10, "两千" -> 2000. Units may be
+// stacked, i.e. "一万亿" -> 10^12, "一亿亿" -> 10^16, and an error is
+// returned if the number overflows an int64.
+func HanziToNumber(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "负")
+	s = strings.TrimPrefix(s, "负")
+	if s == "" {
+		return 0, fmt.Errorf("expected chinese numerals")
+	}
+
+	// the magnitude of math.MinInt64 is one more than math.MaxInt64
+	limit := uint64(math.MaxInt64)
+	if neg {
+		limit++
+	}
+	overflow := fmt.Errorf("'%s' overflows int64", s)
+
+	// parts are the values of each unit of 万 or more, largest first
+	var parts []uint64
+	var section, num uint64
+	digit := false
+	for _, r := range s {
+		if d := hanziDigit(r); d >= 0 {
+			if digit && num != 0 {
+				return 0, fmt.Errorf("unexpected digit '%c' in '%s'", r, s)
+			}
+			num = uint64(d)
+			digit = true
+			continue
+		}
+		digit = false
+
+		switch r {
+		case '十', '百', '千':
+
+			// 十 may be written without a leading one
+			if num == 0 {
+				num = 1
+			}
+			section += num * unitValue(r)
+			num = 0
+
+		case '万', '萬', '亿', '億':
+
+			// the unit multiplies the preceding parts smaller than it,
+			// i.e. 一万亿 is 一万 亿s and 一亿亿 is 一亿 亿s
+			unit := unitValue(r)
+			x := section + num
+			for len(parts) > 0 && parts[len(parts)-1]/unit < unit {
+				x += parts[len(parts)-1]
+				parts = parts[:len(parts)-1]
+			}
+			if x == 0 {
+				return 0, fmt.Errorf("unexpected unit '%c' in '%s'", r, s)
+			}
+			if x > limit/unit {
+				return 0, overflow
+			}
+			parts = append(parts, x*unit)
+			section, num = 0, 0
+
+		default:
+			return 0, fmt.Errorf("unexpected rune '%c' in '%s'", r, s)
+		}
+	}
+
+	total := section + num
+	for _, p := range parts {
+		if total > limit-p {
+			return 0, overflow
+		}
+		total += p
+	}
+	if neg {
+		return -int64(total), nil
+	}
+	return int64(total), nil
+}
+
+// hanziDigit returns the value of a chinese numeral digit, or -1.
+func hanziDigit(r rune) int {
+	switch r {
+	case '〇', '零':
+		return 0
+	case '两', '兩':
+		return 2
+	}
+	for i, d := range hanziDigits {
+		if r == d {
+			return i
+		}
+	}
+	return -1
+}
+
+// unitValue returns the value of a chinese numeral unit.
+func unitValue(r rune) uint64 {
+	switch r {
+	case '十':
+		return 10
+	case '百':
+		return 100
+	case '千':
+		return 1000
+	case '万', '萬':
+		return 1e4
+	case '亿', '億':
+		return 1e8
+	}
+	return 0
+}
+
+// NumberToPinyin returns the pinyin with tone marks for the number
+// written in chinese numerals, i.e. 100 -> "yī bǎi".
+func (d *Dict) NumberToPinyin(n int64) string {
+	return strings.ToLower(PinyinTones(d.HanziToPinyin(NumberToHanzi(n))))
+}
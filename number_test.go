@@ -0,0 +1,135 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+var numberTests = map[int64]string{
+	0:          "零",
+	2:          "二",
+	10:         "十",
+	11:         "十一",
+	20:         "二十",
+	100:        "一百",
+	101:        "一百零一",
+	110:        "一百一十",
+	1001:       "一千零一",
+	1234:       "一千二百三十四",
+	10000:      "一万",
+	10001:      "一万零一",
+	10100:      "一万零一百",
+	100000:     "十万",
+	120000:     "十二万",
+	1000010:    "一百万零一十",
+	100000001:  "一亿零一",
+	100010000:  "一亿零一万",
+	123456789:  "一亿二千三百四十五万六千七百八十九",
+	-15:        "负十五",
+	1000000000: "十亿",
+
+	// units stack past 10^12
+	1000000000000:        "一万亿",
+	1000000000001:        "一万亿零一",
+	1000100000000:        "一万零一亿",
+	123456789012:         "一千二百三十四亿五千六百七十八万九千零一十二",
+	10000000000000000:    "一亿亿",
+	10000000000000001:    "一亿亿零一",
+	105000000000000000:   "十亿亿五千万亿",
+	math.MaxInt64:        "九百二十二亿亿三千三百七十二万零三百六十八亿五千四百七十七万五千八百零七",
+	-1234567890123456789: "负一百二十三亿亿四千五百六十七万八千九百零一亿二千三百四十五万六千七百八十九",
+}
+
+func TestNumberToHanzi(t *testing.T) {
+	for n, want := range numberTests {
+		if got := NumberToHanzi(n); got != want {
+			t.Errorf("%d - got '%s' (want '%s')", n, got, want)
+		}
+	}
+}
+
+func TestHanziToNumber(t *testing.T) {
+	for want, s := range numberTests {
+		got, err := HanziToNumber(s)
+		if err != nil {
+			t.Errorf("'%s' - %v", s, err)
+		} else if got != want {
+			t.Errorf("'%s' - got %d (want %d)", s, got, want)
+		}
+	}
+
+	// alternate forms
+	alts := map[string]int64{
+		"两千":  2000,
+		"一十":  10,
+		"〇":   0,
+		"一萬":  10000,
+		"三億":  300000000,
+		"一百十": 110,
+		"一萬億": 1000000000000,
+		"一万万": 100000000,
+	}
+	for s, want := range alts {
+		got, err := HanziToNumber(s)
+		if err != nil || got != want {
+			t.Errorf("'%s' - got %d, %v (want %d)", s, got, err, want)
+		}
+	}
+
+	// numbers overflowing int64
+	for _, s := range []string{"一千亿亿", "九百二十二亿亿三千三百七十二万零三百六十八亿五千四百七十七万五千八百零八"} {
+		if _, err := HanziToNumber(s); err == nil || !strings.Contains(err.Error(), "overflows") {
+			t.Errorf("'%s' - got %v (want overflow)", s, err)
+		}
+	}
+	if got, err := HanziToNumber("负九百二十二亿亿三千三百七十二万零三百六十八亿五千四百七十七万五千八百零八"); err != nil || got != math.MinInt64 {
+		t.Errorf("got %d, %v (want %d)", got, err, int64(math.MinInt64))
+	}
+
+	// invalid input
+	for _, s := range []string{"", "负", "abc", "一二", "万", "十x", "一兆", "亿亿"} {
+		if _, err := HanziToNumber(s); err == nil {
+			t.Errorf("'%s' - expected error", s)
+		}
+	}
+}
+
+func TestNumberRoundTrip(t *testing.T) {
+	nums := []int64{math.MaxInt64, math.MinInt64, math.MinInt64 + 1}
+	for n := int64(0); n < 100000; n += 7 {
+		nums = append(nums, n, n*100003, n*922337203685, -n*10000000001)
+	}
+	for _, n := range nums {
+		got, err := HanziToNumber(NumberToHanzi(n))
+		if err != nil || got != n {
+			t.Errorf("%d - got %d, %v (hanzi '%s')", n, got, err, NumberToHanzi(n))
+		}
+	}
+}
+
+func TestNumberToPinyin(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"一 一 [yi1] /one/",
+		"二 二 [er4] /two/",
+		"十 十 [shi2] /ten/",
+		"百 百 [bai3] /hundred/",
+		"零 零 [ling2] /zero/",
+	)...)
+
+	tests := map[int64]string{
+		2:   "èr",
+		12:  "shí èr",
+		100: "yī bǎi",
+		101: "yī bǎi líng yī",
+	}
+	for n, want := range tests {
+		if got := d.NumberToPinyin(n); got != want {
+			t.Errorf("%d - got '%s' (want '%s')", n, got, want)
+		}
+	}
+}
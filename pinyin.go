@@ -0,0 +1,429 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSyllableLen is the length of the longest plaintext pinyin syllable.
+const maxSyllableLen = 6
+
+// IsPinyin returns true if the string is made up entirely of valid pinyin
+// syllables, with or without tones/tone numbers, i.e. "zhongwen",
+// "Zhong1 wen2" or "Zhōngwén". Spaces and apostrophes are ignored.
+func IsPinyin(s string) bool {
+	return SplitPinyin(s) != nil
+}
+
+// SplitPinyin splits a pinyin string into syllables, preferring the longest
+// valid syllable at each position, i.e. "zhong1wen2" -> [zhong1 wen2].
+// It returns nil if the string is not made up entirely of valid syllables.
+func SplitPinyin(s string) []string {
+	s = strings.ToLower(PinyinToneNums(NormalizePinyin(s)))
+	s = strings.NewReplacer("u:", "v", "ü", "v").Replace(s)
+
+	var syllables []string
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\''
+	}) {
+		parts := splitSyllables(w)
+		if parts == nil {
+			return nil
+		}
+		syllables = append(syllables, parts...)
+	}
+	if len(syllables) == 0 {
+		return nil
+	}
+
+	// restore ü for display
+	for i, p := range syllables {
+		syllables[i] = strings.ReplaceAll(p, "v", "u:")
+	}
+	return syllables
+}
+
+// NormalizePinyin tidies pinyin typed inconsistently, collapsing runs of
+// whitespace and reattaching tone numbers separated from their syllable by
+// spaces, i.e. "zhong 1 wen 2" -> "zhong1 wen2". Syllables following a tone
+// number are separated from it, i.e. "zhong1wen2" -> "zhong1 wen2".
+func NormalizePinyin(s string) string {
+	var words []string
+	for _, w := range strings.Fields(s) {
+
+		// reattach a leading tone number to the previous syllable
+		if n := len(words); n > 0 && isToneNum(w, 0) && !isToneNum(words[n-1], len(words[n-1])-1) {
+			words[n-1] += w[:1]
+			if w = w[1:]; w == "" {
+				continue
+			}
+		}
+
+		// split after tone numbers between letters
+		start := 0
+		for i := 1; i < len(w)-1; i++ {
+			if !isToneNum(w, i) {
+				continue
+			}
+			prev, _ := utf8.DecodeLastRuneInString(w[start:i])
+			next, _ := utf8.DecodeRuneInString(w[i+1:])
+			if unicode.IsLetter(prev) && unicode.IsLetter(next) {
+				words = append(words, w[start:i+1])
+				start = i + 1
+			}
+		}
+		words = append(words, w[start:])
+	}
+	return strings.Join(words, " ")
+}
+
+// isToneNum returns true if the byte at index i of s is a tone number.
+func isToneNum(s string, i int) bool {
+	return i >= 0 && i < len(s) && strings.IndexByte(toneNums, s[i]) >= 0
+}
+
+// PinyinNumberStyleEnd moves inline tone numbers to the end of each
+// syllable, as used by CC-CEDICT, i.e. "Zho1ng we2n" -> "Zhong1 wen2".
+// Words with more than one tone number are left unchanged.
+func PinyinNumberStyleEnd(s string) string {
+	words := strings.Split(s, " ")
+	for i, w := range words {
+		n := strings.IndexAny(w, toneNums)
+		if n <= 0 || n == len(w)-1 || strings.IndexAny(w[n+1:], toneNums) >= 0 {
+			continue
+		}
+		words[i] = w[:n] + w[n+1:] + w[n:n+1]
+	}
+	return strings.Join(words, " ")
+}
+
+// PinyinNumberStyleInline moves tone numbers from the end of each syllable
+// to follow the vowel which takes the tone mark, i.e. "Zhong1 wen2" ->
+// "Zho1ng we2n", choosing the same vowel as PinyinTones. Words with more
+// than one tone number are left unchanged.
+func PinyinNumberStyleInline(s string) string {
+	words := strings.Split(s, " ")
+	for i, w := range words {
+		n := strings.IndexAny(w, toneNums)
+		if n <= 0 || n != len(w)-1 {
+			continue
+		}
+		runes := []rune(w[:n])
+		j := guessToneIndex(w[:n]) + 1
+		if j <= 0 {
+			continue
+		}
+
+		// ü is written as u: so the number follows the colon
+		if j < len(runes) && runes[j] == ':' {
+			j++
+		}
+		words[i] = string(runes[:j]) + w[n:] + string(runes[j:])
+	}
+	return strings.Join(words, " ")
+}
+
+// JoinSyllables joins the space separated syllables of a word, as
+// pinyin is conventionally written, i.e. "Zhōng wén" -> "Zhōngwén".
+// As per the official spelling rules, an apostrophe is inserted before
+// syllables starting with a, e or o, so the boundary isn't ambiguous,
+// i.e. "Xī ān" -> "Xī'ān". Tone numbers are also supported.
+func JoinSyllables(s string) string {
+	var sb strings.Builder
+	for i, p := range strings.Fields(s) {
+		if i > 0 && needsApostrophe(p) {
+			sb.WriteByte('\'')
+		}
+		sb.WriteString(p)
+	}
+	return sb.String()
+}
+
+// needsApostrophe returns true if the syllable starts with a, e or o,
+// with or without a tone mark.
+func needsApostrophe(p string) bool {
+	first := strings.ToLower(StripTones(p))
+	return first != "" && strings.IndexByte("aeo", first[0]) >= 0
+}
+
+// SortByPinyinCollation sorts entries by their pinyin in dictionary order,
+// comparing each syllable ignoring case and then by tone, with neutral tone
+// last, i.e. ā, á, ǎ, à, a then ba. Entries with the same pinyin are sorted
+// lowercase first, then by simplified hanzi.
+func SortByPinyinCollation(entries []*Entry) {
+	keys := make(map[*Entry][]collationKey, len(entries))
+	for _, e := range entries {
+		keys[e] = pinyinCollationKey(e.Pinyin)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if c := compareCollationKeys(keys[a], keys[b]); c != 0 {
+			return c < 0
+		}
+		if a.Pinyin != b.Pinyin {
+			return a.Pinyin > b.Pinyin
+		}
+		return a.Simplified < b.Simplified
+	})
+}
+
+// collationKey is a plaintext syllable and tone, used for sorting.
+type collationKey struct {
+	syllable string
+	tone     byte
+}
+
+// pinyinCollationKey returns the sort key for each syllable of the pinyin.
+func pinyinCollationKey(p string) []collationKey {
+	syllables := SplitPinyin(p)
+	if syllables == nil {
+		syllables = strings.Fields(strings.ToLower(PinyinToneNums(p)))
+	}
+	keys := make([]collationKey, len(syllables))
+	for i, s := range syllables {
+		k := collationKey{tone: '5'}
+		if n := len(s); n > 0 && strings.IndexByte(toneNums, s[n-1]) >= 0 {
+			k.tone = s[n-1]
+			s = s[:n-1]
+		}
+
+		// ü is written as v, so lü sorts after lu
+		k.syllable = strings.ReplaceAll(s, "u:", "v")
+		keys[i] = k
+	}
+	return keys
+}
+
+// compareCollationKeys returns -1, 0 or 1 comparing a with b.
+func compareCollationKeys(a, b []collationKey) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].syllable != b[i].syllable {
+			if a[i].syllable < b[i].syllable {
+				return -1
+			}
+			return 1
+		}
+		if a[i].tone != b[i].tone {
+			if a[i].tone < b[i].tone {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// splitSyllables splits a single word of lowercase pinyin, where ü is
+// written as v, into syllables each with an optional tone number. It
+// backtracks if the longest syllable leaves an invalid remainder.
+func splitSyllables(w string) []string {
+	if w == "" {
+		return []string{}
+	}
+	for n := maxSyllableLen; n > 0; n-- {
+		if n > len(w) || !validSyllables[w[:n]] {
+			continue
+		}
+
+		// include tone number following the syllable
+		end := n
+		if end < len(w) && strings.IndexByte(toneNums, w[end]) >= 0 {
+			end++
+		}
+
+		if rest := splitSyllables(w[end:]); rest != nil {
+			return append([]string{w[:end]}, rest...)
+		}
+	}
+	return nil
+}
+
+// validSyllables is the set of valid plaintext pinyin syllables,
+// with ü written as v.
+var validSyllables = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, s := range strings.Fields(`
+		a ai an ang ao
+		ba bai ban bang bao bei ben beng bi bian biao bie bin bing bo bu
+		ca cai can cang cao ce cei cen ceng cha chai chan chang chao che chen
+		cheng chi chong chou chu chua chuai chuan chuang chui chun chuo ci cong
+		cou cu cuan cui cun cuo
+		da dai dan dang dao de dei den deng di dia dian diao die ding diu dong
+		dou du duan dui dun duo
+		e ei en eng er
+		fa fan fang fei fen feng fo fou fu
+		ga gai gan gang gao ge gei gen geng gong gou gu gua guai guan guang gui
+		gun guo
+		ha hai han hang hao he hei hen heng hm hng hong hou hu hua huai huan
+		huang hui hun huo
+		ji jia jian jiang jiao jie jin jing jiong jiu ju juan jue jun
+		ka kai kan kang kao ke kei ken keng kong kou ku kua kuai kuan kuang kui
+		kun kuo
+		la lai lan lang lao le lei leng li lia lian liang liao lie lin ling liu
+		lo long lou lu luan lun luo lv lve
+		m ma mai man mang mao me mei men meng mi mian miao mie min ming miu mo
+		mou mu
+		n na nai nan nang nao ne nei nen neng ng ni nian niang niao nie nin
+		ning niu nong nou nu nuan nuo nv nve
+		o ou
+		pa pai pan pang pao pei pen peng pi pian piao pie pin ping po pou pu
+		qi qia qian qiang qiao qie qin qing qiong qiu qu quan que qun
+		r ran rang rao re ren reng ri rong rou ru rua ruan rui run ruo
+		sa sai san sang sao se sen seng sha shai shan shang shao she shei shen
+		sheng shi shou shu shua shuai shuan shuang shui shun shuo si song sou su
+		suan sui sun suo
+		ta tai tan tang tao te teng ti tian tiao tie ting tong tou tu tuan tui
+		tun tuo
+		wa wai wan wang wei wen weng wo wu
+		xi xia xian xiang xiao xie xin xing xiong xiu xu xuan xue xun
+		ya yan yang yao ye yi yin ying yo yong you yu yuan yue yun
+		za zai zan zang zao ze zei zen zeng zha zhai zhan zhang zhao zhe zhei
+		zhen zheng zhi zhong zhou zhu zhua zhuai zhuan zhuang zhui zhun zhuo zi
+		zong zou zu zuan zui zun zuo
+	`) {
+		m[s] = true
+	}
+	return m
+}()
+
+// PinyinStyle is the output format used by FormatPinyin.
+type PinyinStyle int
+
+const (
+
+	// ToneMarks formats pinyin with tone marks, i.e. "Zhōng wén".
+	ToneMarks PinyinStyle = iota
+
+	// ToneNumbers formats pinyin with tone numbers, i.e. "Zhong1 wen2".
+	ToneNumbers
+
+	// Plaintext formats pinyin without tones, i.e. "Zhong wen".
+	Plaintext
+
+	// Zhuyin formats pinyin as zhuyin (bopomofo), i.e. "ㄓㄨㄥ ㄨㄣˊ".
+	Zhuyin
+)
+
+// FormatPinyin returns the pinyin, written with either tone marks
+// or tone numbers, converted to the given style.
+func FormatPinyin(s string, style PinyinStyle) string {
+	switch style {
+	case ToneNumbers:
+		return PinyinToneNums(s)
+	case Plaintext:
+		return PinyinPlaintext(s)
+	case Zhuyin:
+		return pinyinZhuyin(s)
+	default:
+		return PinyinTones(PinyinToneNums(s))
+	}
+}
+
+// pinyinZhuyin converts each pinyin syllable to zhuyin, leaving
+// words that aren't made up of valid syllables unchanged.
+func pinyinZhuyin(s string) string {
+	var words []string
+	for _, w := range strings.Fields(PinyinToneNums(s)) {
+		syllables := SplitPinyin(w)
+		if syllables == nil {
+			words = append(words, w)
+			continue
+		}
+		for _, p := range syllables {
+			words = append(words, zhuyinSyllable(p))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// zhuyinSyllable converts a lowercase pinyin syllable,
+// with an optional tone number, to zhuyin.
+func zhuyinSyllable(p string) string {
+	p = strings.ReplaceAll(p, "u:", "v")
+	tone := byte('1')
+	if n := len(p); n > 0 && strings.IndexByte(toneNums, p[n-1]) >= 0 {
+		tone = p[n-1]
+		p = p[:n-1]
+	}
+
+	// split syllable into initial and final
+	z, ok := zhuyinWhole[p]
+	if !ok {
+		initial := ""
+		for _, i := range []string{"zh", "ch", "sh"} {
+			if strings.HasPrefix(p, i) {
+				initial = i
+			}
+		}
+		if initial == "" && len(p) > 1 && zhuyinInitials[p[:1]] != "" {
+			initial = p[:1]
+		}
+		final := p[len(initial):]
+
+		// u is written for ü after j, q and x
+		if initial != "" && strings.Contains("jqx", initial) && strings.HasPrefix(final, "u") {
+			final = "v" + final[1:]
+		}
+
+		f, ok := zhuyinFinals[final]
+		if !ok {
+			return p + string(tone)
+		}
+		z = zhuyinInitials[initial] + f
+	}
+
+	// neutral tone is written before the syllable
+	switch tone {
+	case '2':
+		z += "ˊ"
+	case '3':
+		z += "ˇ"
+	case '4':
+		z += "ˋ"
+	case '5':
+		z = "˙" + z
+	}
+	return z
+}
+
+// zhuyinInitials maps pinyin initials to zhuyin.
+var zhuyinInitials = map[string]string{
+	"b": "ㄅ", "p": "ㄆ", "m": "ㄇ", "f": "ㄈ", "d": "ㄉ", "t": "ㄊ", "n": "ㄋ",
+	"l": "ㄌ", "g": "ㄍ", "k": "ㄎ", "h": "ㄏ", "j": "ㄐ", "q": "ㄑ", "x": "ㄒ",
+	"zh": "ㄓ", "ch": "ㄔ", "sh": "ㄕ", "r": "ㄖ", "z": "ㄗ", "c": "ㄘ", "s": "ㄙ",
+}
+
+// zhuyinFinals maps pinyin finals, with ü written as v, to zhuyin.
+var zhuyinFinals = map[string]string{
+	"a": "ㄚ", "o": "ㄛ", "e": "ㄜ", "ai": "ㄞ", "ei": "ㄟ", "ao": "ㄠ", "ou": "ㄡ",
+	"an": "ㄢ", "en": "ㄣ", "ang": "ㄤ", "eng": "ㄥ", "er": "ㄦ", "ong": "ㄨㄥ",
+	"i": "ㄧ", "ia": "ㄧㄚ", "ie": "ㄧㄝ", "iao": "ㄧㄠ", "iu": "ㄧㄡ", "ian": "ㄧㄢ",
+	"in": "ㄧㄣ", "iang": "ㄧㄤ", "ing": "ㄧㄥ", "iong": "ㄩㄥ",
+	"u": "ㄨ", "ua": "ㄨㄚ", "uo": "ㄨㄛ", "uai": "ㄨㄞ", "ui": "ㄨㄟ", "uan": "ㄨㄢ",
+	"un": "ㄨㄣ", "uang": "ㄨㄤ",
+	"v": "ㄩ", "ve": "ㄩㄝ", "van": "ㄩㄢ", "vn": "ㄩㄣ",
+}
+
+// zhuyinWhole maps pinyin syllables that don't split
+// into a regular initial and final to zhuyin.
+var zhuyinWhole = map[string]string{
+	"zhi": "ㄓ", "chi": "ㄔ", "shi": "ㄕ", "ri": "ㄖ", "zi": "ㄗ", "ci": "ㄘ", "si": "ㄙ",
+	"yi": "ㄧ", "ya": "ㄧㄚ", "yo": "ㄧㄛ", "ye": "ㄧㄝ", "yao": "ㄧㄠ", "you": "ㄧㄡ",
+	"yan": "ㄧㄢ", "yin": "ㄧㄣ", "yang": "ㄧㄤ", "ying": "ㄧㄥ", "yong": "ㄩㄥ",
+	"yu": "ㄩ", "yue": "ㄩㄝ", "yuan": "ㄩㄢ", "yun": "ㄩㄣ",
+	"wu": "ㄨ", "wa": "ㄨㄚ", "wo": "ㄨㄛ", "wai": "ㄨㄞ", "wei": "ㄨㄟ", "wan": "ㄨㄢ",
+	"wen": "ㄨㄣ", "wang": "ㄨㄤ", "weng": "ㄨㄥ",
+	"r": "ㄦ", "m": "ㄇ", "n": "ㄋ", "ng": "ㄫ", "hm": "ㄏㄇ", "hng": "ㄏㄫ",
+}
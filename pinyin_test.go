@@ -0,0 +1,202 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPinyin(t *testing.T) {
+	tests := map[string]string{
+		"zhongwen":    "zhong wen",
+		"Zhong1 wen2": "zhong1 wen2",
+		"zhong1wen2":  "zhong1 wen2",
+		"mei guo ren": "mei guo ren",
+		"xian":        "xian",
+		"xi'an":       "xi an",
+		"lu:4":        "lu:4",
+		"lvse":        "lu: se",
+		"nǚ":          "nu:3",
+		"chuang":      "chuang",
+		"english":     "",
+		"hello":       "",
+		"zhongwenx":   "",
+		"":            "",
+		"3C":          "",
+	}
+	for in, want := range tests {
+		got := strings.Join(SplitPinyin(in), " ")
+		if got != want {
+			t.Errorf("SplitPinyin(%q) got '%s' (want '%s')", in, got, want)
+		}
+		if IsPinyin(in) != (want != "") {
+			t.Errorf("IsPinyin(%q) got %v (want %v)", in, !(want != ""), want != "")
+		}
+	}
+}
+
+func TestFormatPinyin(t *testing.T) {
+	tests := map[PinyinStyle]string{
+		ToneMarks:   "Zhōng wén",
+		ToneNumbers: "Zhong1 wen2",
+		Plaintext:   "Zhong wen",
+		Zhuyin:      "ㄓㄨㄥ ㄨㄣˊ",
+	}
+	for style, want := range tests {
+		for _, in := range []string{"Zhong1 wen2", "Zhōng wén"} {
+			if got := FormatPinyin(in, style); got != want {
+				t.Errorf("%d '%s' - got '%s' (want '%s')", style, in, got, want)
+			}
+		}
+	}
+}
+
+func TestFormatPinyinZhuyin(t *testing.T) {
+	tests := map[string]string{
+		"lu:4":           "ㄌㄩˋ",
+		"nu:3 er2":       "ㄋㄩˇ ㄦˊ",
+		"xue2 sheng5":    "ㄒㄩㄝˊ ˙ㄕㄥ",
+		"ju1 qun2":       "ㄐㄩ ㄑㄩㄣˊ",
+		"shi4 zi4":       "ㄕˋ ㄗˋ",
+		"you3 yong4":     "ㄧㄡˇ ㄩㄥˋ",
+		"wei4 shen2 me5": "ㄨㄟˋ ㄕㄣˊ ˙ㄇㄜ",
+		"Bei3jing1":      "ㄅㄟˇ ㄐㄧㄥ",
+		"gui4 niu2":      "ㄍㄨㄟˋ ㄋㄧㄡˊ",
+		"na3 r5":         "ㄋㄚˇ ˙ㄦ",
+		"an1 e4":         "ㄢ ㄜˋ",
+		"A4 Q":           "ㄚˋ Q",
+	}
+	for in, want := range tests {
+		if got := FormatPinyin(in, Zhuyin); got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
+	}
+}
+
+func TestPinyinNumberStyle(t *testing.T) {
+	tests := map[string]string{
+		"Zhong1 wen2":    "Zho1ng we2n",
+		"Mei3 guo2 ren2": "Me3i guo2 re2n",
+		"lu:4":           "lu:4",
+		"nu:3 er2":       "nu:3 e2r",
+		"lu:e4":          "lu:e4",
+		"xue2 sheng5":    "xue2 she5ng",
+		"hao3 r5":        "ha3o r5",
+		"hm5 ng2":        "hm5 ng2",
+		"A4 Q":           "A4 Q",
+		"3C":             "3C",
+		"":               "",
+	}
+	for end, inline := range tests {
+		if got := PinyinNumberStyleInline(end); got != inline {
+			t.Errorf("PinyinNumberStyleInline('%s') got '%s' (want '%s')", end, got, inline)
+		}
+		if got := PinyinNumberStyleEnd(inline); got != end {
+			t.Errorf("PinyinNumberStyleEnd('%s') got '%s' (want '%s')", inline, got, end)
+		}
+
+		// both styles have the same tone marks
+		if a, b := PinyinTones(end), PinyinTones(inline); a != b {
+			t.Errorf("PinyinTones mismatch '%s' and '%s'", a, b)
+		}
+	}
+
+	// words with multiple tone numbers are unchanged
+	for _, s := range []string{"zhong1wen2", "zho1ngwe2n"} {
+		if got := PinyinNumberStyleInline(s); got != s {
+			t.Errorf("PinyinNumberStyleInline('%s') got '%s'", s, got)
+		}
+		if got := PinyinNumberStyleEnd(s); got != s {
+			t.Errorf("PinyinNumberStyleEnd('%s') got '%s'", s, got)
+		}
+	}
+}
+
+func TestNormalizePinyin(t *testing.T) {
+	tests := map[string]string{
+		"zhong 1 wen 2":   "zhong1 wen2",
+		"zhong1wen2":      "zhong1 wen2",
+		"zhong 1wen 2":    "zhong1 wen2",
+		"  zhong1   wen2": "zhong1 wen2",
+		"Zhōng wén":       "Zhōng wén",
+		"lu: 4 se 4":      "lu:4 se4",
+		"xi1'an1":         "xi1'an1",
+		"zhong1 2":        "zhong1 2",
+		"3 C":             "3 C",
+		"3C":              "3C",
+		"ka3 la1 O K":     "ka3 la1 O K",
+		"":                "",
+	}
+	for in, want := range tests {
+		if got := NormalizePinyin(in); got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
+	}
+}
+
+func TestJoinSyllables(t *testing.T) {
+	tests := map[string]string{
+		"Zhōng wén":   "Zhōngwén",
+		"Xī ān":       "Xī'ān",
+		"Xi1 an1":     "Xi1'an1",
+		"tiān ān mén": "tiān'ānmén",
+		"Yán ān":      "Yán'ān",
+		"nǚ ér":       "nǚ'ér",
+		"pèi ǒu":      "pèi'ǒu",
+		"Ōu zhōu":     "Ōuzhōu",
+		"fang1 an4":   "fang1'an4",
+		"xian1":       "xian1",
+		"":            "",
+	}
+	for in, want := range tests {
+		if got := JoinSyllables(in); got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
+	}
+
+	// hanzi to pinyin output for a single word
+	d := parseTestDict(t, append(testEntries,
+		"西安 西安 [Xi1 an1] /Xi'an, sub-provincial city and capital of Shaanxi/",
+	)...)
+	if got, want := JoinSyllables(PinyinTones(d.HanziToPinyin("西安"))), "Xī'ān"; got != want {
+		t.Errorf("西安 - got '%s' (want '%s')", got, want)
+	}
+}
+
+func TestSortByPinyinCollation(t *testing.T) {
+	var entries []*Entry
+	for _, line := range []string{
+		"爸 爸 [ba4] /father/",
+		"吖 吖 [a1] /phonetic a/",
+		"啊 啊 [a5] /modal particle/",
+		"嗄 嗄 [a2] /what?/",
+		"八 八 [ba1] /eight/",
+		"阿 阿 [A4] /surname/",
+		"阿 阿 [a3] /phonetic a/",
+		"吧 吧 [ba5] /modal particle/",
+		"綠 绿 [lu:4] /green/",
+		"路 路 [lu4] /road/",
+		"阿拉 阿拉 [a1 la1] /Allah/",
+		"阿 阿 [a4] /phonetic a/",
+	} {
+		e := &Entry{}
+		if err := e.Unmarshal(line); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+
+	SortByPinyinCollation(entries)
+	var got []string
+	for _, e := range entries {
+		got = append(got, PinyinTones(e.Pinyin))
+	}
+	want := []string{"ā", "ā lā", "á", "ǎ", "à", "À", "a", "bā", "bà", "ba", "lù", "lǜ"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:  %v\nwant: %v", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"golang.org/x/text/unicode/norm"
+)
+
+// radicalChars maps Kangxi radicals to common characters written with
+// them, as per the kRSKangXi field of the Unihan database. Simplified
+// characters are listed under the radical of their traditional form's
+// Kangxi radical, i.e. 们 under 人. This is a hand-picked table of a few
+// hundred common characters under a few dozen radicals, not the full
+// Unihan data, so most characters don't have a known radical.
+var radicalChars = map[rune]string{
+	'一': "一七三上下不",
+	'丨': "中",
+	'人': "人他们們你住作体休位低但信做停健傅例使便候借像价價件任伙伴偷值倒",
+	'刀': "刀到别別刻前利分切",
+	'口': "口吃喝叫吗嗎吧呢听問唱嘴哭右名吐向告員员啊哪",
+	'囗': "国國四回因园園图圖",
+	'土': "土在地场場城块塊坐基",
+	'大': "大天太夫",
+	'女': "女她好妈媽姐妹奶姓如始婚娘",
+	'子': "子字学學孩存",
+	'心': "心忙快怕想意思感念忘忽怎性情慢愛志态態急恩息悲您",
+	'手': "手打找把报拉拍接推提换換抱指挂掛拿授握",
+	'文': "文",
+	'日': "日明早时時星昨晚春是晴暖晨",
+	'木': "木本李村林果树樹桌校样樣机機板杯楼樓椅",
+	'氏': "民",
+	'水': "水河海洋湖江池汤湯泳洗流游法活没沒波注洞浪深清温溫满滿漂港渴油沙治派消泪淚演激",
+	'火': "火灯燈烧燒点热熱然照煮",
+	'犬': "犬狗猫独獨狼猪",
+	'玉': "王玉玩现現球理",
+	'白': "白的百",
+	'示': "示社神票祝福礼禮",
+	'竹': "竹笔筆第等答算简簡笑",
+	'糸': "糸红紅绿綠给給经經结結级級纸紙练練",
+	'艸': "艸花草茶菜药藥英苦",
+	'行': "行街",
+	'衣': "衣被裤褲袜襪初",
+	'言': "言话話说說语語请請认認识識让讓读讀谢謝该該课課试試词詞记記讲講",
+	'辵': "辵这這过過还還进進远遠近道送边邊运運通",
+	'金': "金钱錢银銀铁鐵错錯钟鐘",
+	'食': "食饭飯饮飲饿餓饺餃馆館",
+}
+
+// radicalVariants maps the forms radicals take as part of a character
+// to their Kangxi radical, i.e. 氵 -> 水.
+var radicalVariants = map[rune]rune{
+	'亻': '人', '刂': '刀', '忄': '心', '扌': '手', '氵': '水',
+	'灬': '火', '犭': '犬', '礻': '示', '⺮': '竹', '纟': '糸',
+	'糹': '糸', '艹': '艸', '衤': '衣', '讠': '言', '訁': '言',
+	'辶': '辵', '钅': '金', '釒': '金', '饣': '食', '飠': '食',
+}
+
+// radicals maps each character in radicalChars to its Kangxi radical.
+var radicals = buildRadicals()
+
+// buildRadicals returns the map of characters to their Kangxi radical.
+func buildRadicals() map[rune]rune {
+	m := make(map[rune]rune)
+	for radical, chars := range radicalChars {
+		for _, c := range chars {
+			m[c] = radical
+		}
+	}
+	return m
+}
+
+// kangxiRadical returns the Kangxi radical for a radical written in any
+// form, including those in the Kangxi Radicals unicode block, i.e. ⽔.
+func kangxiRadical(r rune) rune {
+	if v, ok := radicalVariants[r]; ok {
+		return v
+	}
+	if s := []rune(norm.NFKC.String(string(r))); len(s) == 1 {
+		return s[0]
+	}
+	return r
+}
+
+// GetByRadical returns the single character entries whose traditional or
+// simplified character is written with the Kangxi radical, in the order
+// parsed. The radical may be given in its variant form, i.e. 氵 for 水.
+//
+// Coverage is limited: only a few hundred common characters, under a few
+// dozen radicals, have a known radical, so entries for other characters
+// are never returned, i.e. 洲 isn't returned for 氵, and radicals without
+// listed characters return nil.
+func (d *Dict) GetByRadical(radical rune) []*Entry {
+	d = d.snapshot()
+	radical = kangxiRadical(radical)
+	var results []*Entry
+	for _, e := range d.e {
+		t, s := []rune(e.Traditional), []rune(e.Simplified)
+		if len(t) != 1 || len(s) != 1 {
+			continue
+		}
+		if radicals[t[0]] == radical || radicals[s[0]] == radical {
+			results = append(results, e)
+		}
+	}
+	return results
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetByRadical(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"河 河 [he2] /river/",
+		"海 海 [hai3] /ocean/sea/",
+		"水 水 [shui3] /water/",
+		"湯 汤 [tang1] /soup/",
+		"海洋 海洋 [hai3 yang2] /ocean/",
+		"洲 洲 [zhou1] /continent/island/",
+	)...)
+
+	// simplified returns the simplified hanzi of each entry
+	simplified := func(entries []*Entry) []string {
+		var hanzi []string
+		for _, e := range entries {
+			hanzi = append(hanzi, e.Simplified)
+		}
+		return hanzi
+	}
+
+	// variant and kangxi block forms of the radical are supported, but
+	// only characters in the hand-picked table are found, so 洲 isn't
+	want := []string{"河", "海", "水", "汤"}
+	for _, r := range []rune{'氵', '水', '⽔'} {
+		if got := simplified(d.GetByRadical(r)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%c - got %v (want %v)", r, got, want)
+		}
+	}
+
+	tests := map[rune][]string{
+		'亻': {"人", "你", "们"},
+		'口': nil,
+		'子': {"学"},
+		'大': {"大"},
+		'X': nil,
+	}
+	for r, want := range tests {
+		if got := simplified(d.GetByRadical(r)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%c - got %v (want %v)", r, got, want)
+		}
+	}
+}
+
+func TestRadicalChars(t *testing.T) {
+
+	// each character has a single radical
+	seen := make(map[rune]rune)
+	for radical, chars := range radicalChars {
+		for _, c := range chars {
+			if r, ok := seen[c]; ok {
+				t.Errorf("%c - listed under %c and %c", c, r, radical)
+			}
+			seen[c] = radical
+		}
+	}
+
+	// variants map to listed radicals
+	for v, radical := range radicalVariants {
+		if _, ok := radicalChars[radical]; !ok {
+			t.Errorf("%c - radical %c not listed", v, radical)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"strings"
+)
+
+// readings holds the reading overrides registered with SetReading.
+// It is never modified once built, so snapshots may share it.
+type readings struct {
+	e    []*Entry
+	trie *trie
+}
+
+// SetReading registers the pinyin reading for a word or phrase, which is
+// used by HanziToPinyin and Segment in place of the dictionary entries.
+// The override is matched exactly as written, so traditional and simplified
+// hanzi should be registered separately. Overrides are kept on Reload.
+func (d *Dict) SetReading(hanzi, pinyin string) {
+	hanzi = strings.TrimSpace(hanzi)
+	if hanzi == "" {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.readings = d.readings.with(&Entry{
+		Traditional: hanzi,
+		Simplified:  hanzi,
+		Pinyin:      strings.TrimSpace(pinyin),
+	})
+}
+
+// with returns a copy of the overrides with the entry added,
+// replacing any existing override for the same hanzi.
+func (r *readings) with(e *Entry) *readings {
+	result := &readings{trie: newTrie()}
+	if r != nil {
+		for _, old := range r.e {
+			if old.Traditional != e.Traditional {
+				result.e = append(result.e, old)
+			}
+		}
+	}
+	result.e = append(result.e, e)
+	for id, e := range result.e {
+		result.trie.insert(e.Traditional, id)
+	}
+	return result
+}
+
+// longest returns the length in runes of the longest override prefixing
+// the runes and its entry, or zero and nil if there is no match.
+func (r *readings) longest(runes []rune) (int, *Entry) {
+	if r == nil {
+		return 0, nil
+	}
+	n, id := r.trie.longest(runes)
+	if id < 0 {
+		return 0, nil
+	}
+	return n, r.e[id]
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetReading(t *testing.T) {
+	d := parseTestDict(t, append(testEntries,
+		"重 重 [chong2] /to repeat/again/",
+		"重 重 [zhong4] /heavy/serious/",
+		"慶 庆 [qing4] /to celebrate/",
+		"市 市 [shi4] /market/city/",
+	)...)
+
+	if got, want := d.HanziToPinyin("重庆市"), "Zhong4 qing4 shi4"; got != want {
+		t.Errorf("before - got '%s' (want '%s')", got, want)
+	}
+
+	// overrides are matched before dictionary words
+	d.SetReading("重庆", "Chong2 qing4")
+	if got, want := d.HanziToPinyin("重庆市"), "Chong2qing4 shi4"; got != want {
+		t.Errorf("override - got '%s' (want '%s')", got, want)
+	}
+	if got, want := d.Segment("重庆市"), []string{"重庆", "市"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("segment - got %q (want %q)", got, want)
+	}
+
+	// later overrides replace earlier ones
+	d.SetReading("重庆", "Chong2 qing4 x")
+	d.SetReading("重庆", "Chong2qing4")
+	if got, want := d.HanziToPinyin("重庆"), "Chong2qing4"; got != want {
+		t.Errorf("replace - got '%s' (want '%s')", got, want)
+	}
+
+	// the dictionary entries are unchanged
+	if e := d.GetByHanzi("重庆"); e != nil {
+		t.Errorf("GetByHanzi - got %v (want nil)", e)
+	}
+
+	// overrides are kept when the dict is replaced
+	parsed := parseTestDict(t, testEntries...)
+	d.mutex.Lock()
+	d.replace(parsed)
+	d.mutex.Unlock()
+	if got, want := d.Segment("重庆"), []string{"重庆"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replace - got %q (want %q)", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+// SafeDict is a view of a Dict where lookup methods return copies of
+// entries, so callers can modify results without corrupting the Dict.
+// It wraps each Dict method returning entries, along with HanziToPinyin.
+// Other methods, such as Segment or ToSimplified, return no entries and
+// are called on the Dict itself.
+type SafeDict struct {
+	d *Dict
+}
+
+// Safe returns a view of the Dict where lookups return cloned entries.
+func (d *Dict) Safe() *SafeDict {
+	return &SafeDict{d: d}
+}
+
+// GetByHanzi returns a copy of the Dict entry for the hanzi, if found.
+func (s *SafeDict) GetByHanzi(hanzi string) *Entry {
+	if e := s.d.GetByHanzi(hanzi); e != nil {
+		return e.Clone()
+	}
+	return nil
+}
+
+// GetAllByHanzi returns copies of all Dict entries for the hanzi.
+func (s *SafeDict) GetAllByHanzi(hanzi string) []*Entry {
+	return cloneEntries(s.d.GetAllByHanzi(hanzi))
+}
+
+// GetByPinyin returns copies of entries matching the pinyin.
+func (s *SafeDict) GetByPinyin(pinyin string) []*Entry {
+	return cloneEntries(s.d.GetByPinyin(pinyin))
+}
+
+// GetByMeaning returns copies of entries containing the meaning.
+func (s *SafeDict) GetByMeaning(meaning string, opts ...MeaningOption) []*Entry {
+	return cloneEntries(s.d.GetByMeaning(meaning, opts...))
+}
+
+// SingleCharEntries returns copies of entries with a single character.
+func (s *SafeDict) SingleCharEntries() []*Entry {
+	return cloneEntries(s.d.SingleCharEntries())
+}
+
+// Idioms returns copies of entries marked as idioms.
+func (s *SafeDict) Idioms() []*Entry {
+	return cloneEntries(s.d.Idioms())
+}
+
+// FourCharIdioms returns copies of idiom entries with four characters.
+func (s *SafeDict) FourCharIdioms() []*Entry {
+	return cloneEntries(s.d.FourCharIdioms())
+}
+
+// EntryAt returns a copy of the entry at index i, as per Dict.EntryAt.
+func (s *SafeDict) EntryAt(i int) (*Entry, bool) {
+	if e, ok := s.d.EntryAt(i); ok {
+		return e.Clone(), true
+	}
+	return nil, false
+}
+
+// Search returns copies of entries matching the query.
+func (s *SafeDict) Search(query string) []*Entry {
+	return cloneEntries(s.d.Search(query))
+}
+
+// FuzzyHanzi returns copies of entries with hanzi similar to the input.
+func (s *SafeDict) FuzzyHanzi(hanzi string, maxDist int) []*Entry {
+	return cloneEntries(s.d.FuzzyHanzi(hanzi, maxDist))
+}
+
+// MeasureWordsFor returns copies of the classifier entries for the noun.
+func (s *SafeDict) MeasureWordsFor(noun string) []*Entry {
+	return cloneEntries(s.d.MeasureWordsFor(noun))
+}
+
+// Homophones returns copies of entries sharing a reading with the char.
+func (s *SafeDict) Homophones(char string, matchTone bool) []*Entry {
+	return cloneEntries(s.d.Homophones(char, matchTone))
+}
+
+// ContainingChar returns copies of entries containing the char.
+func (s *SafeDict) ContainingChar(char string) []*Entry {
+	return cloneEntries(s.d.ContainingChar(char))
+}
+
+// WordsWithPrefix returns copies of entries beginning with the hanzi.
+func (s *SafeDict) WordsWithPrefix(hanzi string) []*Entry {
+	return cloneEntries(s.d.WordsWithPrefix(hanzi))
+}
+
+// WordsWithSuffix returns copies of entries ending with the hanzi.
+func (s *SafeDict) WordsWithSuffix(hanzi string) []*Entry {
+	return cloneEntries(s.d.WordsWithSuffix(hanzi))
+}
+
+// Neighbors returns copies of the entries before and after the entry.
+func (s *SafeDict) Neighbors(e *Entry, n int) (before, after []*Entry) {
+	before, after = s.d.Neighbors(e, n)
+	return cloneEntries(before), cloneEntries(after)
+}
+
+// GetByPinyinGrouped returns copies of entries matching the pinyin,
+// grouped by their tone number pinyin.
+func (s *SafeDict) GetByPinyinGrouped(pinyin string) map[string][]*Entry {
+	groups := s.d.GetByPinyinGrouped(pinyin)
+	for k, entries := range groups {
+		groups[k] = cloneEntries(entries)
+	}
+	return groups
+}
+
+// GetByPinyinRanked returns copies of entries matching the pinyin,
+// with exact tone matches first.
+func (s *SafeDict) GetByPinyinRanked(pinyin string) []*Entry {
+	return cloneEntries(s.d.GetByPinyinRanked(pinyin))
+}
+
+// SearchPinyinFuzzy returns copies of entries with similar pinyin.
+func (s *SafeDict) SearchPinyinFuzzy(pinyin string) []ScoredEntry {
+	scored := s.d.SearchPinyinFuzzy(pinyin)
+	for i := range scored {
+		scored[i].Entry = scored[i].Entry.Clone()
+	}
+	return scored
+}
+
+// GetByMeaningMatches returns copies of entries containing the meaning,
+// along with the meaning which matched.
+func (s *SafeDict) GetByMeaningMatches(meaning string) []MeaningMatch {
+	matches := s.d.GetByMeaningMatches(meaning)
+	for i := range matches {
+		matches[i].Entry = matches[i].Entry.Clone()
+	}
+	return matches
+}
+
+// GetByMeaningPOS returns copies of entries containing the meaning,
+// which have the part of speech tag.
+func (s *SafeDict) GetByMeaningPOS(meaning, pos string) []*Entry {
+	return cloneEntries(s.d.GetByMeaningPOS(meaning, pos))
+}
+
+// GetByMeaningStemmed returns copies of entries matching the meaning
+// by word stems.
+func (s *SafeDict) GetByMeaningStemmed(meaning string) []*Entry {
+	return cloneEntries(s.d.GetByMeaningStemmed(meaning))
+}
+
+// GetByMeaningWords returns copies of entries with meanings containing
+// the words.
+func (s *SafeDict) GetByMeaningWords(words []string, matchAll bool) []*Entry {
+	return cloneEntries(s.d.GetByMeaningWords(words, matchAll))
+}
+
+// GetByMeaningQuery returns copies of entries matching the query.
+func (s *SafeDict) GetByMeaningQuery(q string) []*Entry {
+	return cloneEntries(s.d.GetByMeaningQuery(q))
+}
+
+// GetByRadical returns copies of entries for characters with the radical.
+func (s *SafeDict) GetByRadical(radical rune) []*Entry {
+	return cloneEntries(s.d.GetByRadical(radical))
+}
+
+// HanziToPinyin converts hanzi to pinyin, as per Dict.HanziToPinyin.
+func (s *SafeDict) HanziToPinyin(hanzi string) string {
+	return s.d.HanziToPinyin(hanzi)
+}
+
+// cloneEntries returns a deep copy of each entry.
+func cloneEntries(entries []*Entry) []*Entry {
+	if entries == nil {
+		return nil
+	}
+	clones := make([]*Entry, len(entries))
+	for i, e := range entries {
+		clones[i] = e.Clone()
+	}
+	return clones
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"sync"
+	"testing"
+)
+
+// mutate modifies the entry, as a careless caller might.
+func mutate(e *Entry) {
+	e.Pinyin = "changed"
+	e.Meanings[0] = "changed"
+	e.Meanings = append(e.Meanings, "added")
+}
+
+func TestSafe(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	s := d.Safe()
+
+	// modify results concurrently, run with -race
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				mutate(s.GetByHanzi("中文"))
+				for _, e := range s.GetAllByHanzi("中") {
+					mutate(e)
+				}
+				for _, e := range s.GetByPinyin("zhong1 guo2") {
+					mutate(e)
+				}
+				for _, e := range s.GetByMeaning("Chinese language") {
+					mutate(e)
+				}
+				for _, e := range s.Search("zhongguo") {
+					mutate(e)
+				}
+				before, after := s.Neighbors(d.GetByHanzi("中國"), 2)
+				for _, e := range append(before, after...) {
+					mutate(e)
+				}
+				for _, m := range s.GetByMeaningMatches("Chinese") {
+					mutate(m.Entry)
+				}
+				for _, se := range s.SearchPinyinFuzzy("zhongwen") {
+					mutate(se.Entry)
+				}
+				for _, entries := range s.GetByPinyinGrouped("zhong") {
+					for _, e := range entries {
+						mutate(e)
+					}
+				}
+				if e, ok := s.EntryAt(0); ok {
+					mutate(e)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// dict entries are unchanged
+	want := parseTestDict(t, testEntries...)
+	for i, e := range d.e {
+		if e.Marshal() != want.e[i].Marshal() {
+			t.Errorf("got %s (want %s)", e.Marshal(), want.e[i].Marshal())
+		}
+	}
+
+	if e, ok := s.EntryAt(d.Len()); e != nil || ok {
+		t.Errorf("got %v, %v (want nil, false)", e, ok)
+	}
+	if e := s.GetByHanzi("國"); e != nil {
+		t.Errorf("got %s (want nil)", e.Marshal())
+	}
+	if got := s.HanziToPinyin("中文"); got != d.HanziToPinyin("中文") {
+		t.Errorf("got '%s'", got)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Script is a form of written chinese, either simplified or traditional.
+type Script int
+
+const (
+
+	// Simplified is the script used in mainland China and Singapore.
+	Simplified Script = iota
+
+	// Traditional is the script used in Taiwan, Hong Kong and Macau.
+	Traditional
+)
+
+// ToSimplified converts text in traditional or mixed script to simplified,
+// i.e. "我們是中國人" -> "我们是中国人". Words are converted using the
+// longest matching entries, and unknown characters are left as-is.
+func (d *Dict) ToSimplified(s string) string {
+	return d.convertScript(s, Simplified)
+}
+
+// ToTraditional converts text in simplified or mixed script to traditional,
+// i.e. "我们是中国人" -> "我們是中國人". Words are converted using the
+// longest matching entries, and unknown characters are left as-is.
+func (d *Dict) ToTraditional(s string) string {
+	return d.convertScript(s, Traditional)
+}
+
+// HanziToPinyinVia converts hanzi to pinyin as per HanziToPinyin, after
+// first converting the text to the script. This gives consistent readings
+// for input which mixes traditional and simplified characters.
+func (d *Dict) HanziToPinyinVia(s string, script Script) string {
+	return d.HanziToPinyin(d.convertScript(s, script))
+}
+
+// SimplifiedChars returns each character of the text which differs between
+// scripts, mapped to its counterpart in the other script, i.e. 們 -> 们 and
+// 们 -> 們. Characters are mapped using the entries they appear in.
+func (d *Dict) SimplifiedChars(s string) map[rune]rune {
+	d = d.snapshot()
+	m := make(map[rune]rune)
+	for _, r := range s {
+		if c, ok := d.toSimp[r]; ok {
+			m[r] = c
+		} else if c, ok := d.toTrad[r]; ok {
+			m[r] = c
+		}
+	}
+	return m
+}
+
+// convertScript converts the hanzi words in the text to the script,
+// keeping all other characters, including whitespace, unchanged.
+// Characters not part of any word are converted individually.
+// If the Dict failed to load, the text is returned unchanged.
+func (d *Dict) convertScript(s string, script Script) string {
+	d = d.snapshot()
+	runes := []rune(s)
+
+	var sb strings.Builder
+	for i := 0; i < len(runes); {
+		if unicode.In(runes[i], unicode.Han) {
+			if n, id := d.trie.longest(runes[i:]); id >= 0 {
+				e := d.e[id]
+				if script == Traditional {
+					sb.WriteString(e.Traditional)
+				} else {
+					sb.WriteString(e.Simplified)
+				}
+				i += n
+				continue
+			}
+
+			// convert single character
+			chars := d.toSimp
+			if script == Traditional {
+				chars = d.toTrad
+			}
+			if c, ok := chars[runes[i]]; ok {
+				sb.WriteRune(c)
+				i++
+				continue
+			}
+		}
+		sb.WriteRune(runes[i])
+		i++
+	}
+	return sb.String()
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToSimplified(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	tests := map[string]string{
+		"":           "",
+		"我們是中國人":     "我们是中国人",
+		"我們是中国人":     "我们是中国人",
+		"我们是中国人":     "我们是中国人",
+		"大學 學生, abc": "大学 学生, abc",
+		"龘學":         "龘学",
+	}
+	for in, want := range tests {
+		if got := d.ToSimplified(in); got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
+	}
+}
+
+func TestToTraditional(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+	tests := map[string]string{
+		"我们是中国人": "我們是中國人",
+		"我們是中国人": "我們是中國人",
+		"大学 学生。": "大學 學生。",
+	}
+	for in, want := range tests {
+		if got := d.ToTraditional(in); got != want {
+			t.Errorf("'%s' - got '%s' (want '%s')", in, got, want)
+		}
+	}
+}
+
+func TestHanziToPinyinVia(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	// mixed input is converted consistently
+	mixed := "我們是中国人"
+	want := d.HanziToPinyin("我们是中国人")
+	for _, script := range []Script{Simplified, Traditional} {
+		if got := d.HanziToPinyinVia(mixed, script); got != want {
+			t.Errorf("%d - got '%s' (want '%s')", script, got, want)
+		}
+	}
+}
+
+func TestSimplifiedChars(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	got := d.SimplifiedChars("我们都是中國人")
+	want := map[rune]rune{'们': '們', '國': '国'}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q (want %q)", got, want)
+	}
+	if got := d.SimplifiedChars("們"); got['們'] != '们' {
+		t.Errorf("got %q (want 們 -> 们)", got)
+	}
+	if got := d.SimplifiedChars("我人 abc"); len(got) != 0 {
+		t.Errorf("got %q (want none)", got)
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"strings"
+	"unicode"
+)
+
+// meaningWords splits english text into lowercase words,
+// discarding punctuation and other non-alphanumeric runes.
+func meaningWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stemWords splits english text into words reduced to their stems.
+func stemWords(s string) []string {
+	words := meaningWords(s)
+	for i, w := range words {
+		words[i] = stem(w)
+	}
+	return words
+}
+
+// stem returns the stem of a lowercase english word, using the Porter
+// stemming algorithm i.e. "running" -> "run", "cats" -> "cat". Words
+// shorter than three letters or containing non-ascii runes are unchanged.
+//
+// Adapted from the reference implementation by Martin Porter,
+// https://tartarus.org/martin/PorterStemmer/
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	p := &porter{b: []byte(word), k: len(word) - 1}
+	p.step1ab()
+	if p.k > 0 {
+		p.step1c()
+		p.step2()
+		p.step3()
+		p.step4()
+		p.step5()
+	}
+	return string(p.b[:p.k+1])
+}
+
+// porter holds the state of a word being stemmed, where b[0:k+1]
+// is the current word and j is a general offset into b.
+type porter struct {
+	b []byte
+	k int
+	j int
+}
+
+// cons returns true if b[i] is a consonant.
+func (p *porter) cons(i int) bool {
+	switch p.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		if i == 0 {
+			return true
+		}
+		return !p.cons(i - 1)
+	}
+	return true
+}
+
+// m measures the number of consonant sequences in b[0:j+1],
+// i.e. <c><v> gives 0, <c>vc<v> gives 1, <c>vcvc<v> gives 2.
+func (p *porter) m() int {
+	n := 0
+	i := 0
+	for {
+		if i > p.j {
+			return n
+		}
+		if !p.cons(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i > p.j {
+				return n
+			}
+			if p.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i > p.j {
+				return n
+			}
+			if !p.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+// vowelInStem returns true if b[0:j+1] contains a vowel.
+func (p *porter) vowelInStem() bool {
+	for i := 0; i <= p.j; i++ {
+		if !p.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doublec returns true if b[j-1:j+1] is a double consonant.
+func (p *porter) doublec(j int) bool {
+	if j < 1 || p.b[j] != p.b[j-1] {
+		return false
+	}
+	return p.cons(j)
+}
+
+// cvc returns true if b[i-2:i+1] is consonant-vowel-consonant
+// and the final consonant is not w, x or y, i.e. hop, cav, lov.
+func (p *porter) cvc(i int) bool {
+	if i < 2 || !p.cons(i) || p.cons(i-1) || !p.cons(i-2) {
+		return false
+	}
+	switch p.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends returns true if b[0:k+1] ends with s, setting j to the stem end.
+func (p *porter) ends(s string) bool {
+	l := len(s)
+	if l > p.k+1 {
+		return false
+	}
+	if string(p.b[p.k-l+1:p.k+1]) != s {
+		return false
+	}
+	p.j = p.k - l
+	return true
+}
+
+// setto replaces b[j+1:k+1] with s, adjusting k.
+func (p *porter) setto(s string) {
+	p.b = append(p.b[:p.j+1], s...)
+	p.k = p.j + len(s)
+}
+
+// r replaces the suffix with s, if the stem has a consonant sequence.
+func (p *porter) r(s string) {
+	if p.m() > 0 {
+		p.setto(s)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing suffixes.
+func (p *porter) step1ab() {
+	if p.b[p.k] == 's' {
+		if p.ends("sses") {
+			p.k -= 2
+		} else if p.ends("ies") {
+			p.setto("i")
+		} else if p.b[p.k-1] != 's' {
+			p.k--
+		}
+	}
+	if p.ends("eed") {
+		if p.m() > 0 {
+			p.k--
+		}
+	} else if (p.ends("ed") || p.ends("ing")) && p.vowelInStem() {
+		p.k = p.j
+		if p.ends("at") {
+			p.setto("ate")
+		} else if p.ends("bl") {
+			p.setto("ble")
+		} else if p.ends("iz") {
+			p.setto("ize")
+		} else if p.doublec(p.k) {
+			p.k--
+			switch p.b[p.k] {
+			case 'l', 's', 'z':
+				p.k++
+			}
+		} else if p.m() == 1 && p.cvc(p.k) {
+			p.setto("e")
+		}
+	}
+}
+
+// step1c turns terminal y to i when there is another vowel in the stem.
+func (p *porter) step1c() {
+	if p.ends("y") && p.vowelInStem() {
+		p.b[p.k] = 'i'
+	}
+}
+
+// step2 maps double suffixes to single ones, i.e. -ization -> -ize.
+func (p *porter) step2() {
+	var suffixes [][2]string
+	switch p.b[p.k-1] {
+	case 'a':
+		suffixes = [][2]string{{"ational", "ate"}, {"tional", "tion"}}
+	case 'c':
+		suffixes = [][2]string{{"enci", "ence"}, {"anci", "ance"}}
+	case 'e':
+		suffixes = [][2]string{{"izer", "ize"}}
+	case 'l':
+		suffixes = [][2]string{{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+			{"eli", "e"}, {"ousli", "ous"}}
+	case 'o':
+		suffixes = [][2]string{{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}}
+	case 's':
+		suffixes = [][2]string{{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+			{"ousness", "ous"}}
+	case 't':
+		suffixes = [][2]string{{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}}
+	case 'g':
+		suffixes = [][2]string{{"logi", "log"}}
+	}
+	for _, s := range suffixes {
+		if p.ends(s[0]) {
+			p.r(s[1])
+			return
+		}
+	}
+}
+
+// step3 handles -ic-, -full, -ness etc.
+func (p *porter) step3() {
+	var suffixes [][2]string
+	switch p.b[p.k] {
+	case 'e':
+		suffixes = [][2]string{{"icate", "ic"}, {"ative", ""}, {"alize", "al"}}
+	case 'i':
+		suffixes = [][2]string{{"iciti", "ic"}}
+	case 'l':
+		suffixes = [][2]string{{"ical", "ic"}, {"ful", ""}}
+	case 's':
+		suffixes = [][2]string{{"ness", ""}}
+	}
+	for _, s := range suffixes {
+		if p.ends(s[0]) {
+			p.r(s[1])
+			return
+		}
+	}
+}
+
+// step4 removes -ant, -ence etc. in context <c>vcvc<v>.
+func (p *porter) step4() {
+	var suffixes []string
+	switch p.b[p.k-1] {
+	case 'a':
+		suffixes = []string{"al"}
+	case 'c':
+		suffixes = []string{"ance", "ence"}
+	case 'e':
+		suffixes = []string{"er"}
+	case 'i':
+		suffixes = []string{"ic"}
+	case 'l':
+		suffixes = []string{"able", "ible"}
+	case 'n':
+		suffixes = []string{"ant", "ement", "ment", "ent"}
+	case 'o':
+		if p.ends("ion") && p.j >= 0 && (p.b[p.j] == 's' || p.b[p.j] == 't') {
+			break
+		}
+		suffixes = []string{"ou"}
+	case 's':
+		suffixes = []string{"ism"}
+	case 't':
+		suffixes = []string{"ate", "iti"}
+	case 'u':
+		suffixes = []string{"ous"}
+	case 'v':
+		suffixes = []string{"ive"}
+	case 'z':
+		suffixes = []string{"ize"}
+	default:
+		return
+	}
+	if suffixes != nil {
+		found := false
+		for _, s := range suffixes {
+			if p.ends(s) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+	}
+	if p.m() > 1 {
+		p.k = p.j
+	}
+}
+
+// step5 removes a final -e and changes -ll to -l if m() > 1.
+func (p *porter) step5() {
+	p.j = p.k
+	if p.b[p.k] == 'e' {
+		a := p.m()
+		if a > 1 || a == 1 && !p.cvc(p.k-1) {
+			p.k--
+		}
+	}
+	if p.b[p.k] == 'l' && p.doublec(p.k) && p.m() > 1 {
+		p.k--
+	}
+}
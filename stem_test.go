@@ -0,0 +1,78 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"a":              "a",
+		"to":             "to",
+		"run":            "run",
+		"running":        "run",
+		"runs":           "run",
+		"cat":            "cat",
+		"cats":           "cat",
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"hopping":        "hop",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"generalization": "gener",
+		"hopefulness":    "hope",
+		"electrical":     "electr",
+		"adjustment":     "adjust",
+		"controlling":    "control",
+		"rolled":         "roll",
+		"flower":         "flower",
+		"flowers":        "flower",
+		"café":           "café",
+	}
+	for word, want := range tests {
+		if got := stem(word); got != want {
+			t.Errorf("stem(%q) got '%s' (want '%s')", word, got, want)
+		}
+	}
+}
+
+func TestGetByMeaningStemmed(t *testing.T) {
+	d := parseTestDict(t,
+		"跑 跑 [pao3] /to run/to escape/",
+		"跑步 跑步 [pao3 bu4] /to walk quickly/to march/to run/",
+		"貓 猫 [mao1] /cat/CL:隻|只[zhi1]/",
+		"狗 狗 [gou3] /dog/",
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"running", []string{"跑", "跑步"}},
+		{"cats", []string{"猫"}},
+		{"dogs", []string{"狗"}},
+		{"running cats", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		entries := d.GetByMeaningStemmed(test.query)
+		if len(entries) != len(test.want) {
+			t.Errorf("%q: got %d entries (want %d)", test.query, len(entries), len(test.want))
+			continue
+		}
+		for i, e := range entries {
+			if e.Simplified != test.want[i] {
+				t.Errorf("%q: [%d] got '%s' (want '%s')", test.query, i, e.Simplified, test.want[i])
+			}
+		}
+	}
+}
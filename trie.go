@@ -0,0 +1,78 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+// trie is a prefix tree of hanzi words, used to find the longest
+// word at the start of some text in a single traversal. Nodes are
+// numbered, with the root as zero, and edges kept in a single map.
+type trie struct {
+	next  map[trieEdge]int32
+	ids   []int32
+	depth int // length in runes of the longest word
+}
+
+// trieEdge identifies the child of a node for the given rune.
+type trieEdge struct {
+	node int32
+	r    rune
+}
+
+// newTrie returns an empty trie.
+func newTrie() *trie {
+	return &trie{
+		next: make(map[trieEdge]int32),
+		ids:  []int32{-1},
+	}
+}
+
+// insert adds the word to the trie with its entry index.
+// If the word already exists, the first index is kept.
+func (t *trie) insert(s string, id int) {
+	if n := len([]rune(s)); n > t.depth {
+		t.depth = n
+	}
+	node := t.node(s)
+	if t.ids[node] < 0 {
+		t.ids[node] = int32(id)
+	}
+}
+
+// node returns the node for the word, adding nodes as needed.
+func (t *trie) node(s string) int32 {
+	var node int32
+	for _, r := range s {
+		edge := trieEdge{node, r}
+		child, ok := t.next[edge]
+		if !ok {
+			child = int32(len(t.ids))
+			t.next[edge] = child
+			t.ids = append(t.ids, -1)
+		}
+		node = child
+	}
+	return node
+}
+
+// longest returns the length in runes of the longest word prefixing
+// the runes and its entry index, or zero and -1 if there is no match.
+// A nil trie, i.e. of a Dict which failed to load, matches nothing.
+func (t *trie) longest(runes []rune) (int, int) {
+	var node int32
+	n, id := 0, -1
+	if t == nil {
+		return n, id
+	}
+	for i, r := range runes {
+		child, ok := t.next[trieEdge{node, r}]
+		if !ok {
+			break
+		}
+		node = child
+		if t.ids[node] >= 0 {
+			n, id = i+1, int(t.ids[node])
+		}
+	}
+	return n, id
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 John Cramb. All rights reserved.
+// Licensed under the MIT License. See LICENSE in the project root
+// for license information.
+
+package cedict
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// greedyHanziToPinyin is the map based HanziToPinyin implementation,
+// used to confirm trie matching produces identical output.
+func greedyHanziToPinyin(d *Dict, s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return ""
+	}
+	s = ConvertSymbols(s)
+	var sb strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		if !unicode.In(runes[i], unicode.Han) {
+			for ; i < len(runes) && !unicode.In(runes[i], unicode.Han); i++ {
+				sb.WriteRune(unicode.ToLower(runes[i]))
+			}
+			sb.WriteByte(' ')
+			continue
+		}
+		found := false
+		for j := len(runes); j > i; j-- {
+			if e := d.GetByHanzi(string(runes[i:j])); e != nil {
+				if id := d.preferredID(e.Traditional); id >= 0 {
+					e = d.e[id]
+				}
+				i = j
+				found = true
+				sb.WriteString(wordPinyin(e))
+				sb.WriteByte(' ')
+				break
+			}
+		}
+		if !found {
+			sb.WriteRune(runes[i])
+			i++
+		}
+	}
+	p := sb.String()
+	return strings.ToUpper(p[:1]) + strings.TrimSpace(p[1:])
+}
+
+func TestTrie(t *testing.T) {
+	tr := newTrie()
+	tr.insert("中", 0)
+	tr.insert("中國", 1)
+	tr.insert("中國人", 2)
+	tr.insert("中國", 3)
+
+	tests := []struct {
+		s     string
+		n, id int
+	}{
+		{"中國人民", 3, 2},
+		{"中國", 2, 1},
+		{"中文", 1, 0},
+		{"人", 0, -1},
+		{"", 0, -1},
+	}
+	for _, test := range tests {
+		n, id := tr.longest([]rune(test.s))
+		if n != test.n || id != test.id {
+			t.Errorf("longest(%q) got %d, %d (want %d, %d)", test.s, n, id, test.n, test.id)
+		}
+	}
+}
+
+func TestSegment(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	tests := map[string][]string{
+		"":           {},
+		"我們都是中國人":    {"我們", "都", "是", "中國人"},
+		"人民银行旁边一行人":  {"人民", "银行", "旁", "边", "一行", "人"},
+		"你好 abc 中文!": {"你好", "abc", "中文", "!"},
+		"我的大王！":      {"我", "的", "大", "王", "！"},
+	}
+	for s, want := range tests {
+		got := d.Segment(s)
+		if len(got) == 0 && len(want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Segment(%q) got %q (want %q)", s, got, want)
+		}
+	}
+}
+
+func TestHanziToPinyinTrie(t *testing.T) {
+	d := parseTestDict(t, testEntries...)
+
+	tests := []string{
+		"",
+		"我們都是中國人",
+		"人民銀行旁边一行人abc字母【路牌】，",
+		"中 國人",
+		"X中國 人",
+		strings.Repeat("中國人民銀行", 20),
+		"你好，我的大王！",
+	}
+	for _, s := range tests {
+		got := d.HanziToPinyin(s)
+		want := greedyHanziToPinyin(d, s)
+		if got != want {
+			t.Errorf("\ngot:  '%s'\nwant: '%s'\n", got, want)
+		}
+	}
+}
+
+func BenchmarkSegment(b *testing.B) {
+	d := New()
+	if err := d.Err(); err != nil {
+		b.Skip(err)
+	}
+	s := strings.Repeat("人民银行旁边一行人，平行宇宙发行股票。", 20)
+	b.Run("Trie", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			d.HanziToPinyin(s)
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			greedyHanziToPinyin(d, s)
+		}
+	})
+}